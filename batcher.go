@@ -0,0 +1,152 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Queues writes and executes them in a single transaction once enough have accumulated or enough time has passed.
+// Queued writes aren't durable until flushed, so a crash loses at most one batch,
+// and a write may be delayed by up to the flush interval.
+// Batches are flushed while holding the database's lock (see Lock), which other users of the database
+// should hold too, and which must not be held when calling Exec, Flush or Close.
+type Batcher struct {
+	db         *Database
+	flushEvery int
+	interval   time.Duration
+	lock       sync.Mutex
+	queue      []batchedExec
+	timer      *time.Timer
+	err        error
+	closed     bool
+}
+
+// A queued write.
+type batchedExec struct {
+	sql  string
+	args []interface{}
+}
+
+// Returns a new batcher flushing after flushEvery writes or flushInterval since the first queued write (if positive).
+func (db *Database) Batcher(flushEvery int, flushInterval time.Duration) *Batcher {
+	return &Batcher{db: db, flushEvery: flushEvery, interval: flushInterval}
+}
+
+// Queues the write, flushing the batch if it's full.
+// An error of a flush triggered by the interval is returned by the next call.
+func (b *Batcher) Exec(sql string, args ...interface{}) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return errors.New("batcher closed")
+	}
+	if err := b.err; err != nil {
+		b.err = nil
+		return err
+	}
+	b.queue = append(b.queue, batchedExec{sql, args})
+	if len(b.queue) >= b.flushEvery {
+		return b.flush()
+	}
+	if b.timer == nil && b.interval > 0 {
+		b.timer = time.AfterFunc(b.interval, func() {
+			b.lock.Lock()
+			defer b.lock.Unlock()
+			if err := b.flush(); err != nil {
+				b.err = err
+			}
+		})
+	}
+	return nil
+}
+
+// Executes the queued writes in a transaction under the database's lock.
+// The queue is only cleared once the transaction commits. If a write fails, only it is dropped
+// and the others stay queued for the next flush.
+func (b *Batcher) flush() error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.queue) == 0 {
+		return nil
+	}
+	b.db.Lock()
+	defer b.db.Unlock()
+	failed, err := b.commit()
+	if err != nil {
+		if failed >= 0 {
+			b.queue = append(b.queue[:failed], b.queue[failed+1:]...)
+		}
+		return err
+	}
+	b.queue = nil
+	return nil
+}
+
+// Executes the queued writes in a transaction and returns the index of the failed write (or -1) on error.
+func (b *Batcher) commit() (int, error) {
+	stmts := make(map[string]*Statement)
+	defer func() {
+		for _, stmt := range stmts {
+			stmt.Close()
+		}
+	}()
+	tx, err := b.db.Begin()
+	if err != nil {
+		return -1, err
+	}
+	for i, e := range b.queue {
+		stmt, ok := stmts[e.sql]
+		if !ok {
+			if stmt, err = b.db.NewStatement(e.sql); err != nil {
+				tx.Rollback()
+				return i, err
+			}
+			stmts[e.sql] = stmt
+		}
+		if err := stmt.ExecArgs(e.args...); err != nil {
+			tx.Rollback()
+			return i, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		// A busy commit leaves the transaction open.
+		if b.db.InTransaction() {
+			tx.Rollback()
+		}
+		return -1, err
+	}
+	return -1, nil
+}
+
+// Executes the queued writes.
+func (b *Batcher) Flush() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if err := b.err; err != nil {
+		b.err = nil
+		return err
+	}
+	return b.flush()
+}
+
+// Flushes the queued writes and stops accepting new ones.
+func (b *Batcher) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	if err := b.err; err != nil {
+		b.err = nil
+		return err
+	}
+	return b.flush()
+}
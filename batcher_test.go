@@ -0,0 +1,79 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBatcher(t *testing.T) {
+	path := t.TempDir() + "/batch.db"
+	db, err := NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExecute(t, db, "CREATE TABLE items (n INTEGER)")
+	commits := 0
+	db.SetCommitGuard(func() error {
+		commits++
+		return nil
+	})
+	b := db.Batcher(25, time.Hour)
+	for i := 0; i < 100; i++ {
+		if err := b.Exec("INSERT INTO items VALUES (?)", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if commits != 4 {
+		t.Errorf("got %d commits for 100 writes, want 4", commits)
+	}
+	db.Close()
+	db, err = NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if n, _ := db.queryInt64("SELECT count(*) FROM items"); n != 100 {
+		t.Errorf("got %d rows after reopening, want 100", n)
+	}
+}
+
+func TestBatcherKeepsQueueOnFailure(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (n INTEGER NOT NULL)")
+	veto := errors.New("vetoed")
+	vetoes := 1
+	db.SetCommitGuard(func() error {
+		if vetoes > 0 {
+			vetoes--
+			return veto
+		}
+		return nil
+	})
+	b := db.Batcher(100, 0)
+	for _, v := range []interface{}{1, 2, nil, 3} {
+		if err := b.Exec("INSERT INTO items VALUES (?)", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The failing write is dropped and the others stay queued.
+	if err := b.Flush(); err == nil {
+		t.Fatal("flushing a NULL into a NOT NULL column succeeded")
+	}
+	if err := b.Flush(); err != veto {
+		t.Fatalf("got %v, want the veto", err)
+	}
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := db.queryInt64("SELECT count(*) FROM items"); n != 3 {
+		t.Errorf("got %d rows, want 3", n)
+	}
+}
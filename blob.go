@@ -0,0 +1,59 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <stdlib.h>
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// The size of chunks written by WriteBlob.
+const blobChunkSize = 64 * 1024
+
+// Binds the i-th column as a blob of n zero bytes.
+func (stmt *Statement) BindZeroBlob(i int, n int) {
+	stmt.markBound(i, nil)
+	C.sqlite3_bind_zeroblob(stmt.stmt, C.int(i), C.int(n))
+}
+
+// Writes data into the blob stored in the given row (which must already have the same size, see BindZeroBlob).
+func (db *Database) WriteBlob(table, column string, rowid int64, data []byte) error {
+	cmain := C.CString("main")
+	defer C.free(unsafe.Pointer(cmain))
+	ctable := C.CString(table)
+	defer C.free(unsafe.Pointer(ctable))
+	ccolumn := C.CString(column)
+	defer C.free(unsafe.Pointer(ccolumn))
+	var blob *C.sqlite3_blob
+	s := C.sqlite3_blob_open(db.db, cmain, ctable, ccolumn, C.sqlite3_int64(rowid), 1, &blob)
+	if s != C.SQLITE_OK {
+		err := errors.New(C.GoString(C.sqlite3_errmsg(db.db)))
+		C.sqlite3_blob_close(blob)
+		return err
+	}
+	defer C.sqlite3_blob_close(blob)
+	if size := int(C.sqlite3_blob_bytes(blob)); size != len(data) {
+		return fmt.Errorf("blob size mismatch (%d bytes stored, %d bytes provided)", size, len(data))
+	}
+	for off := 0; off < len(data); off += blobChunkSize {
+		end := off + blobChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[off:end]
+		s := C.sqlite3_blob_write(blob, unsafe.Pointer(&chunk[0]), C.int(len(chunk)), C.int(off))
+		if s != C.SQLITE_OK {
+			return errors.New(C.GoString(C.sqlite3_errmsg(db.db)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestWriteBlob(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE files (data BLOB)")
+	data := make([]byte, 3*blobChunkSize+123)
+	rand.New(rand.NewSource(1)).Read(data)
+	stmt, err := db.NewStatement("INSERT INTO files VALUES (?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt.BindZeroBlob(1, len(data))
+	err = stmt.Step()
+	stmt.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rowid := db.LastInsertRowID()
+	if err := db.WriteBlob("files", "data", rowid, data[:10]); err == nil {
+		t.Error("writing a blob of the wrong size succeeded")
+	}
+	if err := db.WriteBlob("files", "data", rowid, data); err != nil {
+		t.Fatal(err)
+	}
+	stmt, err = db.NewStatement("SELECT data FROM files WHERE rowid = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	stmt.BindInt64(1, rowid)
+	var got []byte
+	if err := stmt.StepRows(func() { got = stmt.ColumnBlob(0) }); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("read back %d bytes that differ from the %d written", len(got), len(data))
+	}
+}
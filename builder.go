@@ -0,0 +1,45 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"sort"
+	"strings"
+)
+
+// Returns a WHERE clause (without the keyword) matching all the column values, with quoted identifiers
+// and the values as arguments in column order. Nil values are matched with IS NULL.
+// The clause is empty if there are no filters.
+func BuildWhere(filters map[string]interface{}) (clause string, args []interface{}) {
+	cols := make([]string, 0, len(filters))
+	for col := range filters {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	conds := make([]string, 0, len(cols))
+	for _, col := range cols {
+		if v := filters[col]; v == nil {
+			conds = append(conds, QuoteIdentifier(col)+" IS NULL")
+		} else {
+			conds = append(conds, QuoteIdentifier(col)+" = ?")
+			args = append(args, v)
+		}
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// Returns an ORDER BY term (without the keyword) for the column with explicit NULL ordering.
+func OrderBy(column string, desc bool, nullsFirst bool) string {
+	term := QuoteIdentifier(column)
+	if desc {
+		term += " DESC"
+	} else {
+		term += " ASC"
+	}
+	if nullsFirst {
+		return term + " NULLS FIRST"
+	}
+	return term + " NULLS LAST"
+}
@@ -0,0 +1,51 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildWhere(t *testing.T) {
+	clause, args := BuildWhere(map[string]interface{}{"name": "alpha", "deleted_at": nil, "qty": 3, `odd"col`: true})
+	want := `"deleted_at" IS NULL AND "name" = ? AND "odd""col" = ? AND "qty" = ?`
+	if clause != want {
+		t.Errorf("got clause %s, want %s", clause, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"alpha", true, 3}) {
+		t.Errorf("got args %v, want [alpha true 3]", args)
+	}
+	if clause, args := BuildWhere(nil); clause != "" || len(args) != 0 {
+		t.Errorf("got %q %v for no filters, want an empty clause", clause, args)
+	}
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (name TEXT, qty INTEGER, deleted_at TEXT); INSERT INTO items VALUES ('alpha', 3, NULL), ('alpha', 3, 'yesterday'), ('beta', 3, NULL)")
+	clause, args = BuildWhere(map[string]interface{}{"name": "alpha", "qty": 3, "deleted_at": nil})
+	if n, err := db.queryInt64("SELECT count(*) FROM items WHERE "+clause, args...); err != nil || n != 1 {
+		t.Errorf("got %d matching rows (%v), want 1", n, err)
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	for _, c := range []struct {
+		desc, nullsFirst bool
+		want             string
+	}{
+		{false, false, `"qty" ASC NULLS LAST`},
+		{false, true, `"qty" ASC NULLS FIRST`},
+		{true, false, `"qty" DESC NULLS LAST`},
+		{true, true, `"qty" DESC NULLS FIRST`},
+	} {
+		if got := OrderBy("qty", c.desc, c.nullsFirst); got != c.want {
+			t.Errorf("OrderBy(qty, %v, %v) = %s, want %s", c.desc, c.nullsFirst, got, c.want)
+		}
+	}
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (qty INTEGER); INSERT INTO items VALUES (1), (NULL), (2)")
+	if n, err := db.queryInt64("SELECT qty IS NULL FROM items ORDER BY " + OrderBy("qty", true, true) + " LIMIT 1"); err != nil || n != 1 {
+		t.Errorf("got %d (%v), want NULL first", n, err)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+#include <sqlite3.h>
+
+typedef struct {
+	int timeout;
+	sqlite3_int64 waited;
+} busy_state;
+
+// Sleeps like SQLite's default busy handler, recording the time spent waiting.
+static int busy_wait(void* arg, int count) {
+	static const int delays[] = { 1, 2, 5, 10, 15, 20, 25, 25, 25, 50, 50, 100 };
+	static const int totals[] = { 0, 1, 3, 8, 18, 33, 53, 78, 103, 128, 178, 228 };
+	busy_state* s = arg;
+	int n = sizeof(delays) / sizeof(delays[0]);
+	int delay, prior;
+	if (count < n) {
+		delay = delays[count];
+		prior = totals[count];
+	} else {
+		delay = delays[n - 1];
+		prior = totals[n - 1] + delay * (count - (n - 1));
+	}
+	if (prior + delay > s->timeout) {
+		delay = s->timeout - prior;
+		if (delay <= 0) return 0;
+	}
+	sqlite3_sleep(delay);
+	s->waited += delay;
+	return 1;
+}
+
+static inline int sqlite3_busy_wait(sqlite3* db, busy_state* s) { return sqlite3_busy_handler(db, s ? busy_wait : 0, s); }
+
+extern int goContextBusy(uintptr_t, int);
+static int context_busy(void* arg, int count) { return goContextBusy((uintptr_t)arg, count); }
+static inline int sqlite3_busy_context(sqlite3* db, uintptr_t h) { return sqlite3_busy_handler(db, context_busy, (void*)h); }
+*/
+import "C"
+
+import (
+	"context"
+	"time"
+)
+
+// A busy handler waiting until its context is cancelled or the maximum wait elapses.
+type contextBusy struct {
+	ctx     context.Context
+	maxWait time.Duration
+	start   time.Time
+	state   *C.busy_state
+}
+
+// Context-aware busy handlers.
+var contextBusyHandlers handles[*contextBusy]
+
+// Returns the connection's busy state, allocating it if needed.
+func (db *Database) busyState() *C.busy_state {
+	if db.busy == nil {
+		db.busy = C.calloc(1, C.sizeof_busy_state)
+	}
+	return (*C.busy_state)(db.busy)
+}
+
+// Sets the busy timeout (0 to fail immediately on locks).
+// Setting PRAGMA busy_timeout directly bypasses BusyTimeout and BusyWaitTotal.
+func (db *Database) SetBusyTimeout(d time.Duration) {
+	db.clearContextBusy()
+	s := db.busyState()
+	s.timeout = C.int(d / time.Millisecond)
+	if s.timeout > 0 {
+		C.sqlite3_busy_wait(db.db, s)
+	} else {
+		C.sqlite3_busy_wait(db.db, nil)
+	}
+}
+
+// Returns the busy timeout set with SetBusyTimeout.
+func (db *Database) BusyTimeout() time.Duration {
+	if db.busy == nil {
+		return 0
+	}
+	return time.Duration(db.busyState().timeout) * time.Millisecond
+}
+
+// Returns the total time spent waiting on locks by the busy handler.
+func (db *Database) BusyWaitTotal() time.Duration {
+	if db.busy == nil {
+		return 0
+	}
+	return time.Duration(db.busyState().waited) * time.Millisecond
+}
+
+// Runs fn with the busy timeout temporarily set to d, restoring the previous timeout
+// or context-aware busy handler afterwards.
+func (db *Database) WithBusyTimeout(d time.Duration, fn func() error) error {
+	prev := db.BusyTimeout()
+	var h *contextBusy
+	if db.busyCtx != 0 {
+		h = contextBusyHandlers.get(db.busyCtx)
+	}
+	db.SetBusyTimeout(d)
+	defer func() {
+		db.SetBusyTimeout(prev)
+		if h != nil {
+			db.SetContextBusyHandler(h.ctx, h.maxWait)
+		}
+	}()
+	return fn()
+}
+
+// Installs the busy handler again on a reopened connection.
+func (db *Database) restoreBusyHandler() {
+	if db.busyCtx != 0 {
+		C.sqlite3_busy_context(db.db, C.uintptr_t(db.busyCtx))
+	} else if db.busy != nil && db.busyState().timeout > 0 {
+		C.sqlite3_busy_wait(db.db, db.busyState())
+	}
+}
+
+// Installs a busy handler that retries on locks until ctx is cancelled or maxWait elapses
+// for a single lock, after which the operation fails with "database is locked".
+// Unlike SetBusyTimeout, the wait can be cut short by cancelling ctx. SetBusyTimeout removes the handler.
+func (db *Database) SetContextBusyHandler(ctx context.Context, maxWait time.Duration) {
+	db.clearContextBusy()
+	db.busyCtx = contextBusyHandlers.add(&contextBusy{ctx: ctx, maxWait: maxWait, state: db.busyState()})
+	C.sqlite3_busy_context(db.db, C.uintptr_t(db.busyCtx))
+}
+
+// Removes the context-aware busy handler's state.
+func (db *Database) clearContextBusy() {
+	if db.busyCtx == 0 {
+		return
+	}
+	C.sqlite3_busy_wait(db.db, nil)
+	contextBusyHandlers.remove(db.busyCtx)
+	db.busyCtx = 0
+}
+
+// Waits before SQLite retries the count-th time and returns zero to give up.
+func runContextBusy(id uintptr, count int) C.int {
+	h := contextBusyHandlers.get(id)
+	if h == nil || h.ctx.Err() != nil {
+		return 0
+	}
+	now := time.Now()
+	if count == 0 {
+		h.start = now
+	}
+	remaining := h.maxWait - now.Sub(h.start)
+	if remaining <= 0 {
+		return 0
+	}
+	delay := 100 * time.Millisecond
+	if count < 7 {
+		delay = time.Millisecond << count
+	}
+	if delay > remaining {
+		delay = remaining
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-h.ctx.Done():
+		return 0
+	case <-timer.C:
+	}
+	h.state.waited += C.sqlite3_int64(time.Since(now) / time.Millisecond)
+	return 1
+}
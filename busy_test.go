@@ -0,0 +1,107 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Returns two connections to a new database file, the first holding an exclusive lock.
+func lockedDatabase(t *testing.T) (locker, waiter *Database) {
+	t.Helper()
+	path := t.TempDir() + "/busy.db"
+	locker, err := NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(locker.Close)
+	waiter, err = NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(waiter.Close)
+	mustExecute(t, locker, "CREATE TABLE items (name TEXT); BEGIN EXCLUSIVE")
+	return locker, waiter
+}
+
+func TestContextBusyHandlerCancel(t *testing.T) {
+	_, waiter := lockedDatabase(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	waiter.SetContextBusyHandler(ctx, time.Minute)
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	if err := waiter.Execute("INSERT INTO items VALUES ('alpha')"); err == nil {
+		t.Fatal("a write succeeded while the database was locked")
+	}
+	if d := time.Since(start); d < 100*time.Millisecond || d > 2*time.Second {
+		t.Errorf("waited %v, want to give up shortly after the context was cancelled", d)
+	}
+}
+
+func TestContextBusyHandlerMaxWait(t *testing.T) {
+	_, waiter := lockedDatabase(t)
+	waiter.SetContextBusyHandler(context.Background(), 100*time.Millisecond)
+	start := time.Now()
+	if err := waiter.Execute("INSERT INTO items VALUES ('alpha')"); err == nil {
+		t.Fatal("a write succeeded while the database was locked")
+	}
+	if d := time.Since(start); d < 100*time.Millisecond || d > 2*time.Second {
+		t.Errorf("waited %v, want about 100ms", d)
+	}
+}
+
+func TestContextBusyHandlerAcquires(t *testing.T) {
+	locker, waiter := lockedDatabase(t)
+	waiter.SetContextBusyHandler(context.Background(), 10*time.Second)
+	time.AfterFunc(100*time.Millisecond, func() { locker.Execute("COMMIT") })
+	if err := waiter.Execute("INSERT INTO items VALUES ('alpha')"); err != nil {
+		t.Errorf("the write failed after the lock was released: %v", err)
+	}
+	if waiter.BusyWaitTotal() == 0 {
+		t.Error("the time spent waiting wasn't recorded")
+	}
+}
+
+func TestWithBusyTimeout(t *testing.T) {
+	db := newTestDatabase(t)
+	db.SetBusyTimeout(time.Second)
+	fail := errors.New("failed")
+	err := db.WithBusyTimeout(10*time.Millisecond, func() error {
+		if d := db.BusyTimeout(); d != 10*time.Millisecond {
+			t.Errorf("got busy timeout %v inside fn, want 10ms", d)
+		}
+		return fail
+	})
+	if err != fail {
+		t.Errorf("got %v, want the error of fn", err)
+	}
+	if d := db.BusyTimeout(); d != time.Second {
+		t.Errorf("got busy timeout %v after fn, want 1s", d)
+	}
+}
+
+func TestWithBusyTimeoutRestoresContextHandler(t *testing.T) {
+	_, waiter := lockedDatabase(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	waiter.SetContextBusyHandler(ctx, time.Minute)
+	err := waiter.WithBusyTimeout(10*time.Millisecond, func() error {
+		return waiter.Execute("INSERT INTO items VALUES ('alpha')")
+	})
+	if err == nil {
+		t.Fatal("a write succeeded while the database was locked")
+	}
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	if err := waiter.Execute("INSERT INTO items VALUES ('alpha')"); err == nil {
+		t.Fatal("a write succeeded while the database was locked")
+	}
+	if d := time.Since(start); d < 100*time.Millisecond || d > 2*time.Second {
+		t.Errorf("waited %v, want the context-aware handler to wait until the context was cancelled", d)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <stdlib.h>
+#include <string.h>
+#include <sqlite3.h>
+
+// An array of integers bound with the "carray" pointer type.
+typedef struct {
+	sqlite3_int64* values;
+	int n;
+} carray_data;
+
+typedef struct {
+	sqlite3_vtab_cursor base;
+	carray_data* data;
+	sqlite3_int64 row;
+} carray_cursor;
+
+static int carray_connect(sqlite3* db, void* aux, int argc, const char* const* argv, sqlite3_vtab** vtab, char** err) {
+	int rc = sqlite3_declare_vtab(db, "CREATE TABLE x(value, pointer HIDDEN)");
+	if (rc != SQLITE_OK) return rc;
+	*vtab = sqlite3_malloc(sizeof(sqlite3_vtab));
+	if (!*vtab) return SQLITE_NOMEM;
+	memset(*vtab, 0, sizeof(sqlite3_vtab));
+	return SQLITE_OK;
+}
+
+static int carray_disconnect(sqlite3_vtab* vtab) {
+	sqlite3_free(vtab);
+	return SQLITE_OK;
+}
+
+static int carray_best_index(sqlite3_vtab* vtab, sqlite3_index_info* info) {
+	int i;
+	for (i = 0; i < info->nConstraint; i++) {
+		const struct sqlite3_index_constraint* c = &info->aConstraint[i];
+		if (c->iColumn == 1 && c->op == SQLITE_INDEX_CONSTRAINT_EQ) {
+			if (!c->usable) return SQLITE_CONSTRAINT;
+			info->aConstraintUsage[i].argvIndex = 1;
+			info->aConstraintUsage[i].omit = 1;
+			info->idxNum = 1;
+			info->estimatedCost = 1;
+			info->estimatedRows = 100;
+			return SQLITE_OK;
+		}
+	}
+	info->idxNum = 0;
+	info->estimatedCost = 2147483647;
+	info->estimatedRows = 2147483647;
+	return SQLITE_OK;
+}
+
+static int carray_open(sqlite3_vtab* vtab, sqlite3_vtab_cursor** cur) {
+	*cur = sqlite3_malloc(sizeof(carray_cursor));
+	if (!*cur) return SQLITE_NOMEM;
+	memset(*cur, 0, sizeof(carray_cursor));
+	return SQLITE_OK;
+}
+
+static int carray_close(sqlite3_vtab_cursor* cur) {
+	sqlite3_free(cur);
+	return SQLITE_OK;
+}
+
+static int carray_filter(sqlite3_vtab_cursor* cur, int idxNum, const char* idxStr, int argc, sqlite3_value** argv) {
+	carray_cursor* c = (carray_cursor*)cur;
+	c->data = idxNum ? sqlite3_value_pointer(argv[0], "carray") : 0;
+	c->row = 0;
+	return SQLITE_OK;
+}
+
+static int carray_next(sqlite3_vtab_cursor* cur) {
+	((carray_cursor*)cur)->row++;
+	return SQLITE_OK;
+}
+
+static int carray_eof(sqlite3_vtab_cursor* cur) {
+	carray_cursor* c = (carray_cursor*)cur;
+	return !c->data || c->row >= c->data->n;
+}
+
+static int carray_column(sqlite3_vtab_cursor* cur, sqlite3_context* ctx, int i) {
+	carray_cursor* c = (carray_cursor*)cur;
+	if (i == 0) sqlite3_result_int64(ctx, c->data->values[c->row]);
+	return SQLITE_OK;
+}
+
+static int carray_rowid(sqlite3_vtab_cursor* cur, sqlite3_int64* rowid) {
+	*rowid = ((carray_cursor*)cur)->row + 1;
+	return SQLITE_OK;
+}
+
+static sqlite3_module carray_module = {
+	.xConnect = carray_connect,
+	.xBestIndex = carray_best_index,
+	.xDisconnect = carray_disconnect,
+	.xOpen = carray_open,
+	.xClose = carray_close,
+	.xFilter = carray_filter,
+	.xNext = carray_next,
+	.xEof = carray_eof,
+	.xColumn = carray_column,
+	.xRowid = carray_rowid,
+};
+
+static void carray_free(void* p) {
+	free(((carray_data*)p)->values);
+	free(p);
+}
+
+static inline int sqlite3_create_carray(sqlite3* db) { return sqlite3_create_module(db, "carray", &carray_module, 0); }
+
+static inline int sqlite3_bind_carray(sqlite3_stmt* stmt, int i, sqlite3_int64* values, int n) {
+	carray_data* data = malloc(sizeof(carray_data));
+	if (!data) {
+		free(values);
+		return SQLITE_NOMEM;
+	}
+	data->values = values;
+	data->n = n;
+	return sqlite3_bind_pointer(stmt, i, data, "carray", carray_free);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// Registers the carray table-valued function, which returns the elements of an array bound with BindIntArray,
+// e.g. SELECT * FROM t WHERE id IN (SELECT value FROM carray(?)).
+func (db *Database) EnableCArray() error {
+	if C.sqlite3_create_carray(db.db) != C.SQLITE_OK {
+		return errors.New(C.GoString(C.sqlite3_errmsg(db.db)))
+	}
+	return nil
+}
+
+// Binds the i-th column as an array for the carray table-valued function.
+func (stmt *Statement) BindIntArray(i int, vals []int64) error {
+	var p *C.sqlite3_int64
+	if len(vals) > 0 {
+		p = (*C.sqlite3_int64)(C.malloc(C.size_t(len(vals)) * C.sizeof_sqlite3_int64))
+		C.memcpy(unsafe.Pointer(p), unsafe.Pointer(&vals[0]), C.size_t(len(vals))*C.sizeof_sqlite3_int64)
+	}
+	if C.sqlite3_bind_carray(stmt.stmt, C.int(i), p, C.int(len(vals))) != C.SQLITE_OK {
+		return errors.New(C.GoString(C.sqlite3_errmsg(stmt.db.db)))
+	}
+	stmt.markBound(i, vals)
+	return nil
+}
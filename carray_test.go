@@ -0,0 +1,40 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import "testing"
+
+func TestBindIntArray(t *testing.T) {
+	db := newTestDatabase(t)
+	if err := db.EnableCArray(); err != nil {
+		t.Fatal(err)
+	}
+	mustExecute(t, db, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT); INSERT INTO items VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, 'd')")
+	stmt, err := db.NewStatement("SELECT name FROM items WHERE id IN (SELECT value FROM carray(?)) ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	for _, tc := range []struct {
+		ids  []int64
+		want string
+	}{
+		{[]int64{4, 2, 9}, "bd"},
+		{nil, ""},
+		{[]int64{1, 2, 3, 4}, "abcd"},
+	} {
+		stmt.Reset()
+		if err := stmt.BindIntArray(1, tc.ids); err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		if err := stmt.StepRows(func() { got += stmt.ColumnText(0) }); err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("ids %v: got %q, want %q", tc.ids, got, tc.want)
+		}
+	}
+}
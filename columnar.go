@@ -0,0 +1,111 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+
+// The type of a column in a columnar result.
+type ColumnarType int
+
+const (
+	ColumnarInt64 ColumnarType = iota
+	ColumnarFloat64
+	ColumnarText
+	ColumnarBlob
+)
+
+// A column of a columnar result. Only the slice matching Type is populated
+// and it has a zero value at every row where Null is true.
+type ColumnarColumn struct {
+	Name     string
+	Type     ColumnarType
+	Int64s   []int64
+	Float64s []float64
+	Strings  []string
+	Blobs    [][]byte
+	Null     []bool
+}
+
+// A query result stored column by column.
+type ColumnarResult struct {
+	Columns []ColumnarColumn
+	Rows    int
+}
+
+// Runs the query and buffers the result in per-column typed slices.
+// A column's type is the storage class of its first non-NULL value (text if there's none)
+// and later values are converted to it.
+func (db *Database) QueryColumnar(sql string, args ...interface{}) (*ColumnarResult, error) {
+	stmt, err := db.NewStatement(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	if err := stmt.BindAll(args...); err != nil {
+		return nil, err
+	}
+	res := &ColumnarResult{Columns: make([]ColumnarColumn, stmt.ColumnCount())}
+	typed := make([]bool, len(res.Columns))
+	for i := range res.Columns {
+		res.Columns[i].Name = stmt.ColumnName(i)
+		res.Columns[i].Type = ColumnarText
+	}
+	if err := stmt.StepRows(func() {
+		for i := range res.Columns {
+			col := &res.Columns[i]
+			t := C.sqlite3_column_type(stmt.stmt, C.int(i))
+			if !typed[i] && t != C.SQLITE_NULL {
+				typed[i] = true
+				switch t {
+				case C.SQLITE_INTEGER:
+					col.Type = ColumnarInt64
+				case C.SQLITE_FLOAT:
+					col.Type = ColumnarFloat64
+				case C.SQLITE_BLOB:
+					col.Type = ColumnarBlob
+				}
+				// Earlier rows were all NULL; pad the typed slice to match.
+				col.Strings = nil
+				col.appendZeros(res.Rows)
+			}
+			col.Null = append(col.Null, t == C.SQLITE_NULL)
+			if t == C.SQLITE_NULL {
+				col.appendZeros(1)
+				continue
+			}
+			switch col.Type {
+			case ColumnarInt64:
+				col.Int64s = append(col.Int64s, stmt.ColumnInt64(i))
+			case ColumnarFloat64:
+				col.Float64s = append(col.Float64s, stmt.ColumnDouble(i))
+			case ColumnarText:
+				col.Strings = append(col.Strings, stmt.ColumnText(i))
+			case ColumnarBlob:
+				col.Blobs = append(col.Blobs, stmt.ColumnBlob(i))
+			}
+		}
+		res.Rows++
+	}); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Appends n zero values to the column's typed slice.
+func (col *ColumnarColumn) appendZeros(n int) {
+	switch col.Type {
+	case ColumnarInt64:
+		col.Int64s = append(col.Int64s, make([]int64, n)...)
+	case ColumnarFloat64:
+		col.Float64s = append(col.Float64s, make([]float64, n)...)
+	case ColumnarText:
+		col.Strings = append(col.Strings, make([]string, n)...)
+	case ColumnarBlob:
+		col.Blobs = append(col.Blobs, make([][]byte, n)...)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueryColumnar(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, `CREATE TABLE items (id INTEGER, price REAL, name TEXT);
+		INSERT INTO items VALUES (1, NULL, 'alpha'), (NULL, 2.5, 'beta'), (3, 3.5, NULL)`)
+	res, err := db.QueryColumnar("SELECT id, price, name FROM items WHERE id IS NULL OR id > ? ORDER BY rowid", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Rows != 3 || len(res.Columns) != 3 {
+		t.Fatalf("got %d rows and %d columns, want 3 and 3", res.Rows, len(res.Columns))
+	}
+	id, price, name := res.Columns[0], res.Columns[1], res.Columns[2]
+	if id.Name != "id" || id.Type != ColumnarInt64 || !reflect.DeepEqual(id.Int64s, []int64{1, 0, 3}) {
+		t.Errorf("got id column %+v", id)
+	}
+	// The first value is NULL, so the type comes from the second one.
+	if price.Type != ColumnarFloat64 || !reflect.DeepEqual(price.Float64s, []float64{0, 2.5, 3.5}) {
+		t.Errorf("got price column %+v", price)
+	}
+	if name.Type != ColumnarText || !reflect.DeepEqual(name.Strings, []string{"alpha", "beta", ""}) {
+		t.Errorf("got name column %+v", name)
+	}
+	for _, c := range []struct {
+		col  ColumnarColumn
+		null []bool
+	}{{id, []bool{false, true, false}}, {price, []bool{true, false, false}}, {name, []bool{false, false, true}}} {
+		if !reflect.DeepEqual(c.col.Null, c.null) {
+			t.Errorf("column %s: got nulls %v, want %v", c.col.Name, c.col.Null, c.null)
+		}
+	}
+	if id.Float64s != nil || id.Strings != nil || price.Int64s != nil {
+		t.Error("slices not matching the column type are populated")
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+extern void goErrorLog(void*, int, char*);
+inline int sqlite3_config_log(int enable) { return sqlite3_config(SQLITE_CONFIG_LOG, enable ? goErrorLog : 0, (void*)0); }
+*/
+import "C"
+
+import "errors"
+
+// The callback receiving SQLite's error log.
+var errorLog func(code int, msg string)
+
+// Sets a callback receiving SQLite's error log (warnings and errors reported by SQLite itself), or nil to disable it.
+// SQLite only allows this before any database is opened, later calls return an error.
+func SetErrorLog(fn func(code int, msg string)) error {
+	enable := C.int(0)
+	if fn != nil {
+		enable = 1
+	}
+	if C.sqlite3_config_log(enable) != C.SQLITE_OK {
+		return errors.New("the error log must be set before any database is opened")
+	}
+	errorLog = fn
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// SQLite's error log collected by all tests.
+var testErrorLog struct {
+	sync.Mutex
+	messages []string
+}
+
+// Returns the number of logged messages containing the text.
+func loggedErrors(text string) int {
+	testErrorLog.Lock()
+	defer testErrorLog.Unlock()
+	n := 0
+	for _, msg := range testErrorLog.messages {
+		if strings.Contains(msg, text) {
+			n++
+		}
+	}
+	return n
+}
+
+// The error log can only be set before any database is opened.
+func TestMain(m *testing.M) {
+	if err := SetErrorLog(func(code int, msg string) {
+		testErrorLog.Lock()
+		testErrorLog.messages = append(testErrorLog.messages, msg)
+		testErrorLog.Unlock()
+	}); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestSetErrorLog(t *testing.T) {
+	before := loggedErrors("corruption")
+	db, err := NewDatabase(corruptDatabase(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.Execute("SELECT count(*) FROM b")
+	if loggedErrors("corruption") == before {
+		t.Error("reading a corrupt database didn't log corruption")
+	}
+	if err := SetErrorLog(nil); err == nil {
+		t.Error("setting the error log after opening a database succeeded")
+	}
+}
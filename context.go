@@ -0,0 +1,115 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A context-bound query in progress.
+type QueryHandle struct {
+	// The SQL text of the query.
+	SQL string
+	// When the query started.
+	Start  time.Time
+	id     uint64
+	cancel context.CancelFunc
+}
+
+// Cancels the query by cancelling its context, which interrupts the connection.
+func (h QueryHandle) Cancel() {
+	h.cancel()
+}
+
+// The context-bound queries in progress on a connection.
+type queryRegistry struct {
+	sync.Mutex
+	handles map[uint64]QueryHandle
+	next    uint64
+}
+
+// Returns the context-bound queries currently in progress, oldest first.
+func (db *Database) ActiveQueries() []QueryHandle {
+	db.queries.Lock()
+	defer db.queries.Unlock()
+	handles := make([]QueryHandle, 0, len(db.queries.handles))
+	for _, h := range db.queries.handles {
+		handles = append(handles, h)
+	}
+	sort.Slice(handles, func(i, j int) bool { return handles[i].id < handles[j].id })
+	return handles
+}
+
+// Registers a query and returns its handle.
+func (db *Database) startQuery(sql string, cancel context.CancelFunc) QueryHandle {
+	db.queries.Lock()
+	defer db.queries.Unlock()
+	if db.queries.handles == nil {
+		db.queries.handles = make(map[uint64]QueryHandle)
+	}
+	db.queries.next++
+	h := QueryHandle{SQL: sql, Start: time.Now(), id: db.queries.next, cancel: cancel}
+	db.queries.handles[h.id] = h
+	return h
+}
+
+// Unregisters a finished query.
+func (db *Database) endQuery(h QueryHandle) {
+	db.queries.Lock()
+	delete(db.queries.handles, h.id)
+	db.queries.Unlock()
+}
+
+// Runs fn, interrupting the connection's statements if the context is done before fn returns.
+// The query is listed in ActiveQueries while it runs.
+func (db *Database) withContext(ctx context.Context, sql string, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	h := db.startQuery(sql, cancel)
+	defer db.endQuery(h)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case <-ctx.Done():
+			C.sqlite3_interrupt(db.db)
+		case <-done:
+		}
+	}()
+	err := fn()
+	close(done)
+	<-finished
+	if err != nil && ctx.Err() != nil {
+		return contextError(ctx.Err())
+	}
+	return err
+}
+
+// Executes an SQL statement, interrupting it if the context is done first.
+func (db *Database) ExecuteContext(ctx context.Context, sql string) error {
+	return db.withContext(ctx, sql, func() error {
+		return db.Execute(sql)
+	})
+}
+
+// Converts a context error to the error returned by this package.
+func contextError(err error) error {
+	if err == context.DeadlineExceeded {
+		return &TimeoutError{Op: "query"}
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestActiveQueries(t *testing.T) {
+	db := newTestDatabase(t)
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- db.ExecuteContext(context.Background(), endlessQuery)
+	}()
+	var active []QueryHandle
+	for deadline := time.Now().Add(5 * time.Second); len(active) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("the running query isn't listed")
+		}
+		time.Sleep(time.Millisecond)
+		active = db.ActiveQueries()
+	}
+	h := active[0]
+	if len(active) != 1 || h.SQL != endlessQuery || h.Start.Before(start) {
+		t.Fatalf("got active queries %v, want the endless query", active)
+	}
+	h.Cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("got %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelling didn't interrupt the query")
+	}
+	if active := db.ActiveQueries(); len(active) != 0 {
+		t.Errorf("got active queries %v after the query finished", active)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import "io"
+
+// A cursor returning the rows of a query in batches on demand.
+type Cursor struct {
+	rows *Rows
+	cols []string
+}
+
+// Runs the query and returns a cursor over its rows, which must be closed.
+func (db *Database) Cursor(sql string, args ...interface{}) (*Cursor, error) {
+	rows, err := db.Query(sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &Cursor{rows: rows, cols: rows.Columns()}, nil
+}
+
+// Returns up to n next rows as maps from column names to values (see ColumnValue).
+// It returns io.EOF once all rows have been fetched.
+func (c *Cursor) Fetch(n int) ([]map[string]interface{}, error) {
+	var batch []map[string]interface{}
+	stmt := c.rows.Statement()
+	for len(batch) < n && c.rows.Next() {
+		row := make(map[string]interface{}, len(c.cols))
+		for i, col := range c.cols {
+			row[col] = stmt.ColumnValue(i)
+		}
+		batch = append(batch, row)
+	}
+	if err := c.rows.Err(); err != nil {
+		return batch, err
+	}
+	if len(batch) == 0 && n > 0 {
+		return nil, io.EOF
+	}
+	return batch, nil
+}
+
+// Closes the cursor.
+func (c *Cursor) Close() {
+	c.rows.Close()
+}
@@ -0,0 +1,45 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestCursorFetch(t *testing.T) {
+	db := newTestDatabase(t)
+	createNums(t, db, 10)
+	c, err := db.Cursor("SELECT n, n * n AS square FROM nums WHERE n > ? ORDER BY n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	var sizes []int
+	next := int64(1)
+	for {
+		batch, err := c.Fetch(3)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, len(batch))
+		for _, row := range batch {
+			if row["n"] != next || row["square"] != next*next {
+				t.Errorf("got row %v, want n %d", row, next)
+			}
+			next++
+		}
+	}
+	if !reflect.DeepEqual(sizes, []int{3, 3, 3, 1}) {
+		t.Errorf("got batch sizes %v, want [3 3 3 1]", sizes)
+	}
+	if _, err := c.Fetch(3); err != io.EOF {
+		t.Errorf("got %v fetching after the end, want io.EOF", err)
+	}
+}
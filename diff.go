@@ -0,0 +1,231 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+)
+
+// A row present in both databases with different values.
+type RowChange struct {
+	// The primary key (or rowid) values.
+	Key []interface{}
+	Old []interface{}
+	New []interface{}
+}
+
+// The differences between the rows of a table in two databases.
+// Rows are lists of column values as returned by ColumnValue.
+type TableDiff struct {
+	// Rows only in the second database.
+	Inserted [][]interface{}
+	// Rows only in the first database.
+	Deleted [][]interface{}
+	// Rows in both databases whose values differ.
+	Changed []RowChange
+}
+
+// Compares the rows of the table in the two databases, matching them by primary key
+// (or rowid if the table has none), and reports what changed from a to b.
+// Both tables are read in key order in a single pass. Text keys are compared bytewise,
+// so the result may be incomplete for tables with keys using a different collation.
+func DiffDatabases(a, b *Database, table string) (*TableDiff, error) {
+	cols, err := a.TableInfo(table)
+	if err != nil {
+		return nil, err
+	}
+	var pk []ColumnInfo
+	for _, col := range cols {
+		if col.PrimaryKey > 0 {
+			pk = append(pk, col)
+		}
+	}
+	sort.Slice(pk, func(i, j int) bool { return pk[i].PrimaryKey < pk[j].PrimaryKey })
+	keys := []string{"rowid"}
+	if len(pk) > 0 {
+		keys = keys[:0]
+		for _, col := range pk {
+			keys = append(keys, QuoteIdentifier(col.Name))
+		}
+	}
+	sql := "SELECT " + strings.Join(keys, ", ") + ", * FROM " + QuoteIdentifier(table) + " ORDER BY " + strings.Join(keys, ", ")
+	ra, err := a.Query(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer ra.Close()
+	rb, err := b.Query(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rb.Close()
+	diff := &TableDiff{}
+	rowA, okA := diffNext(ra)
+	rowB, okB := diffNext(rb)
+	for okA || okB {
+		c := 0
+		switch {
+		case !okA:
+			c = 1
+		case !okB:
+			c = -1
+		default:
+			c = compareRows(rowA[:len(keys)], rowB[:len(keys)])
+		}
+		switch {
+		case c < 0:
+			diff.Deleted = append(diff.Deleted, rowA[len(keys):])
+			rowA, okA = diffNext(ra)
+		case c > 0:
+			diff.Inserted = append(diff.Inserted, rowB[len(keys):])
+			rowB, okB = diffNext(rb)
+		default:
+			if compareRows(rowA[len(keys):], rowB[len(keys):]) != 0 {
+				diff.Changed = append(diff.Changed, RowChange{Key: rowA[:len(keys)], Old: rowA[len(keys):], New: rowB[len(keys):]})
+			}
+			rowA, okA = diffNext(ra)
+			rowB, okB = diffNext(rb)
+		}
+	}
+	if err := ra.Err(); err != nil {
+		return nil, err
+	}
+	if err := rb.Err(); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// Returns the values of the next row.
+func diffNext(rows *Rows) ([]interface{}, bool) {
+	if !rows.Next() {
+		return nil, false
+	}
+	stmt := rows.Statement()
+	row := make([]interface{}, stmt.ColumnCount())
+	for i := range row {
+		row[i] = stmt.ColumnValue(i)
+	}
+	return row, true
+}
+
+// Compares two rows value by value.
+func compareRows(a, b []interface{}) int {
+	for i := range a {
+		if c := compareValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// Compares two column values in SQLite's order (NULL, numbers, text, blobs).
+func compareValues(a, b interface{}) int {
+	ca, cb := valueClass(a), valueClass(b)
+	if ca != cb {
+		return ca - cb
+	}
+	switch va := a.(type) {
+	case int64:
+		if vb, ok := b.(int64); ok {
+			switch {
+			case va < vb:
+				return -1
+			case va > vb:
+				return 1
+			}
+			return 0
+		}
+		return compareFloats(float64(va), b.(float64))
+	case float64:
+		if vb, ok := b.(int64); ok {
+			return compareFloats(va, float64(vb))
+		}
+		return compareFloats(va, b.(float64))
+	case string:
+		return CompareText(va, b.(string))
+	case []byte:
+		return bytes.Compare(va, b.([]byte))
+	}
+	return 0
+}
+
+// Compares two floats, returning -1, 0 or 1.
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Returns the rank of the value's storage class in SQLite's order.
+func valueClass(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case int64, float64:
+		return 1
+	case string:
+		return 2
+	}
+	return 3
+}
+
+// Returns a 64-bit FNV-1a hash of the values of the row with the rowid, or ErrNoRows.
+// Each value is hashed with its storage class (and the length for text and blobs)
+// so rows with the same values in the same column order have the same checksum in any database.
+func (db *Database) RowChecksum(table string, rowid int64) (uint64, error) {
+	stmt, err := db.NewStatement("SELECT * FROM " + QuoteIdentifier(table) + " WHERE rowid = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	if err := stmt.BindAll(rowid); err != nil {
+		return 0, err
+	}
+	h := fnv.New64a()
+	found := false
+	if err := stmt.StepRows(func() {
+		found = true
+		var buf [9]byte
+		for i := 0; i < stmt.ColumnCount(); i++ {
+			var data []byte
+			switch v := stmt.ColumnValue(i).(type) {
+			case nil:
+				buf[0] = 'n'
+			case int64:
+				buf[0] = 'i'
+				binary.BigEndian.PutUint64(buf[1:], uint64(v))
+			case float64:
+				buf[0] = 'f'
+				binary.BigEndian.PutUint64(buf[1:], math.Float64bits(v))
+			case string:
+				buf[0] = 't'
+				binary.BigEndian.PutUint64(buf[1:], uint64(len(v)))
+				data = []byte(v)
+			case []byte:
+				buf[0] = 'b'
+				binary.BigEndian.PutUint64(buf[1:], uint64(len(v)))
+				data = v
+			}
+			h.Write(buf[:])
+			h.Write(data)
+		}
+	}); err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, ErrNoRows
+	}
+	return h.Sum64(), nil
+}
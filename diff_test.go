@@ -0,0 +1,78 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffDatabases(t *testing.T) {
+	a, b := newTestDatabase(t), newTestDatabase(t)
+	for _, db := range []*Database{a, b} {
+		mustExecute(t, db, `CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, price REAL);
+			INSERT INTO items VALUES (1, 'alpha', 1.5), (2, 'beta', 2), (3, 'gamma', 3)`)
+	}
+	mustExecute(t, b, "UPDATE items SET price = 2.5 WHERE id = 2; DELETE FROM items WHERE id = 3; INSERT INTO items VALUES (4, 'delta', 4)")
+	diff, err := DiffDatabases(a, b, "items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]interface{}{{int64(4), "delta", 4.0}}; !reflect.DeepEqual(diff.Inserted, want) {
+		t.Errorf("got inserted %v, want %v", diff.Inserted, want)
+	}
+	if want := [][]interface{}{{int64(3), "gamma", 3.0}}; !reflect.DeepEqual(diff.Deleted, want) {
+		t.Errorf("got deleted %v, want %v", diff.Deleted, want)
+	}
+	want := []RowChange{{Key: []interface{}{int64(2)}, Old: []interface{}{int64(2), "beta", 2.0}, New: []interface{}{int64(2), "beta", 2.5}}}
+	if !reflect.DeepEqual(diff.Changed, want) {
+		t.Errorf("got changed %v, want %v", diff.Changed, want)
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	// In SQLite's order, with integers and floats compared numerically.
+	ordered := []interface{}{nil, int64(-3), -2.5, int64(1), 1.5, int64(2), "B", "a", []byte{0}, []byte{0, 1}}
+	for i, x := range ordered {
+		for j, y := range ordered {
+			got := compareValues(x, y)
+			if (i < j && got >= 0) || (i > j && got <= 0) || (i == j && got != 0) {
+				t.Errorf("compareValues(%#v, %#v) = %d", x, y, got)
+			}
+		}
+	}
+	if compareValues(int64(2), 2.0) != 0 || compareFloats(0.5, 0.5) != 0 {
+		t.Error("equal numbers compare unequal")
+	}
+}
+
+func TestRowChecksum(t *testing.T) {
+	a, b := newTestDatabase(t), newTestDatabase(t)
+	for _, db := range []*Database{a, b} {
+		mustExecute(t, db, "CREATE TABLE items (name TEXT, size INTEGER, data BLOB)")
+		mustExecute(t, db, "INSERT INTO items VALUES ('alpha', 1, x'01'), ('beta', NULL, NULL)")
+	}
+	checksum := func(db *Database, rowid int64) uint64 {
+		t.Helper()
+		sum, err := db.RowChecksum("items", rowid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+	if checksum(a, 1) != checksum(b, 1) || checksum(a, 2) != checksum(b, 2) {
+		t.Error("identical rows have different checksums")
+	}
+	if checksum(a, 1) == checksum(a, 2) {
+		t.Error("different rows have the same checksum")
+	}
+	mustExecute(t, b, "UPDATE items SET size = 2 WHERE rowid = 1")
+	if checksum(a, 1) == checksum(b, 1) {
+		t.Error("a changed value kept the checksum")
+	}
+	if _, err := a.RowChecksum("items", 3); err != ErrNoRows {
+		t.Errorf("got %v for a missing row, want ErrNoRows", err)
+	}
+}
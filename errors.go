@@ -0,0 +1,47 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Returned when a query expected to return a row returns none.
+var ErrNoRows = errors.New("no rows in result")
+
+// Returned when a query expected to return a single row returns more.
+var ErrMultipleRows = errors.New("multiple rows in result")
+
+// An error returned when an operation doesn't complete in time.
+type TimeoutError struct {
+	Op string
+}
+
+// Returns the error message.
+func (err *TimeoutError) Error() string {
+	return err.Op + " timed out"
+}
+
+// Reports that the error is a timeout (see net.Error).
+func (err *TimeoutError) Timeout() bool {
+	return true
+}
+
+// An error of a single row in a batch.
+type RowError struct {
+	Index int
+	Err   error
+}
+
+// Returns the error message.
+func (err *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", err.Index, err.Err)
+}
+
+// Returns the underlying error.
+func (err *RowError) Unwrap() error {
+	return err.Err
+}
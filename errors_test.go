@@ -0,0 +1,28 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// An endless query for testing interruption.
+const endlessQuery = "WITH RECURSIVE s(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM s) SELECT count(*) FROM s"
+
+func TestTimeoutError(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := db.ExecuteContext(ctx, endlessQuery)
+	te, ok := err.(interface{ Timeout() bool })
+	if !ok || !te.Timeout() {
+		t.Fatalf("got %v, want a timeout error", err)
+	}
+	if err.Error() != "query timed out" {
+		t.Errorf("got message %q", err.Error())
+	}
+}
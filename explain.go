@@ -0,0 +1,73 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import "strings"
+
+// A virtual machine instruction as listed by EXPLAIN.
+type VDBEInstruction struct {
+	Addr    int
+	Opcode  string
+	P1      int
+	P2      int
+	P3      int
+	P4      string
+	P5      int
+	Comment string
+}
+
+// Returns the virtual machine program of the query.
+func (db *Database) Explain(sql string, args ...interface{}) ([]VDBEInstruction, error) {
+	stmt, err := db.NewStatement("EXPLAIN " + sql)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	if err := stmt.BindAll(args...); err != nil {
+		return nil, err
+	}
+	var prog []VDBEInstruction
+	if err := stmt.StepRows(func() {
+		prog = append(prog, VDBEInstruction{
+			Addr:    stmt.ColumnInt(0),
+			Opcode:  stmt.ColumnText(1),
+			P1:      stmt.ColumnInt(2),
+			P2:      stmt.ColumnInt(3),
+			P3:      stmt.ColumnInt(4),
+			P4:      stmt.ColumnText(5),
+			P5:      stmt.ColumnInt(6),
+			Comment: stmt.ColumnText(7),
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return prog, nil
+}
+
+// Returns true if every table access in the query plan (EXPLAIN QUERY PLAN) uses a covering index,
+// i.e. the query is answered from indexes without reading table rows.
+func (db *Database) UsesCoveringIndex(sql string, args ...interface{}) (bool, error) {
+	stmt, err := db.NewStatement("EXPLAIN QUERY PLAN " + sql)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+	if err := stmt.BindAll(args...); err != nil {
+		return false, err
+	}
+	accesses, covered := 0, 0
+	if err := stmt.StepRows(func() {
+		detail := stmt.ColumnText(3)
+		if strings.HasPrefix(detail, "SCAN ") || strings.HasPrefix(detail, "SEARCH ") {
+			accesses++
+			if strings.Contains(detail, "COVERING INDEX") {
+				covered++
+			}
+		}
+	}); err != nil {
+		return false, err
+	}
+	return accesses > 0 && covered == accesses, nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import "testing"
+
+func TestExplain(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (name TEXT)")
+	prog, err := db.Explain("SELECT name FROM items WHERE name = ?", "alpha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prog) == 0 {
+		t.Fatal("got an empty program")
+	}
+	opcodes := map[string]bool{}
+	for i, ins := range prog {
+		if ins.Addr != i {
+			t.Errorf("instruction %d has address %d", i, ins.Addr)
+		}
+		opcodes[ins.Opcode] = true
+	}
+	if prog[0].Opcode != "Init" || !opcodes["Halt"] {
+		t.Errorf("got opcodes %v, want an Init first and a Halt", opcodes)
+	}
+}
+
+func TestUsesCoveringIndex(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, qty INTEGER); CREATE INDEX items_name ON items (name)")
+	for _, c := range []struct {
+		sql  string
+		want bool
+	}{
+		{"SELECT name FROM items WHERE name = ?", true},
+		{"SELECT id, name FROM items WHERE name > ?", true},
+		{"SELECT qty FROM items WHERE name = ?", false},
+		{"SELECT * FROM items WHERE qty > ?", false},
+	} {
+		covered, err := db.UsesCoveringIndex(c.sql, "alpha")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if covered != c.want {
+			t.Errorf("%s: got %v, want %v", c.sql, covered, c.want)
+		}
+	}
+}
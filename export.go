@@ -0,0 +1,86 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"time"
+	"unsafe"
+)
+
+//export goErrorLog
+func goErrorLog(_ unsafe.Pointer, code C.int, msg *C.char) {
+	if fn := errorLog; fn != nil {
+		fn(int(code), C.GoString(msg))
+	}
+}
+
+//export goFTS5Create
+func goFTS5Create(factory C.uintptr_t, argv **C.char, argc C.int, out *C.uintptr_t) C.int {
+	tok, rc := fts5Create(uintptr(factory), argv, int(argc))
+	*out = C.uintptr_t(tok)
+	return rc
+}
+
+//export goFTS5Tokenize
+func goFTS5Tokenize(tok C.uintptr_t, ctx unsafe.Pointer, text *C.char, n C.int, xToken unsafe.Pointer) C.int {
+	return fts5Tokenize(uintptr(tok), ctx, text, int(n), xToken)
+}
+
+//export goFTS5Delete
+func goFTS5Delete(tok C.uintptr_t) {
+	fts5Handles.remove(uintptr(tok))
+}
+
+//export goFTS5Destroy
+func goFTS5Destroy(factory C.uintptr_t) {
+	fts5Handles.remove(uintptr(factory))
+}
+
+//export goVFSOpen
+func goVFSOpen(vfs C.uintptr_t, name *C.char, flags C.int) C.int {
+	return vfsResult(vfsHandles.get(uintptr(vfs)).Open(C.GoString(name), int(flags)), C.SQLITE_CANTOPEN)
+}
+
+//export goVFSRead
+func goVFSRead(vfs C.uintptr_t, name *C.char, offset C.sqlite3_int64, n C.int) C.int {
+	return vfsResult(vfsHandles.get(uintptr(vfs)).Read(C.GoString(name), int64(offset), int(n)), C.SQLITE_IOERR_READ)
+}
+
+//export goVFSWrite
+func goVFSWrite(vfs C.uintptr_t, name *C.char, data unsafe.Pointer, n C.int, offset C.sqlite3_int64) C.int {
+	return vfsResult(vfsHandles.get(uintptr(vfs)).Write(C.GoString(name), int64(offset), unsafe.Slice((*byte)(data), int(n))), C.SQLITE_IOERR_WRITE)
+}
+
+//export goVFSSync
+func goVFSSync(vfs C.uintptr_t, name *C.char) C.int {
+	return vfsResult(vfsHandles.get(uintptr(vfs)).Sync(C.GoString(name)), C.SQLITE_IOERR_FSYNC)
+}
+
+//export goVFSClose
+func goVFSClose(vfs C.uintptr_t, name *C.char) C.int {
+	return vfsResult(vfsHandles.get(uintptr(vfs)).Close(C.GoString(name)), C.SQLITE_IOERR_CLOSE)
+}
+
+//export goSlowQuery
+func goSlowQuery(id C.uintptr_t, sql *C.char, ns C.sqlite3_int64) {
+	slowQuery(uintptr(id), C.GoString(sql), time.Duration(ns))
+}
+
+//export goCommitGuard
+func goCommitGuard(id C.uintptr_t) C.int {
+	return runCommitGuard(uintptr(id))
+}
+
+//export goContextBusy
+func goContextBusy(id C.uintptr_t, count C.int) C.int {
+	return runContextBusy(uintptr(id), int(count))
+}
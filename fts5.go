@@ -0,0 +1,116 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+#include <sqlite3.h>
+extern int goFTS5Create(uintptr_t, char**, int, uintptr_t*);
+extern int goFTS5Tokenize(uintptr_t, void*, char*, int, void*);
+extern void goFTS5Delete(uintptr_t);
+extern void goFTS5Destroy(uintptr_t);
+typedef int (*fts5_token_fn)(void*, int, const char*, int, int, int);
+static int fts5_go_create(void* ctx, const char** argv, int argc, Fts5Tokenizer** out) {
+	uintptr_t tok = 0;
+	int rc = goFTS5Create((uintptr_t)ctx, (char**)argv, argc, &tok);
+	*out = (Fts5Tokenizer*)tok;
+	return rc;
+}
+static void fts5_go_delete(Fts5Tokenizer* tok) { goFTS5Delete((uintptr_t)tok); }
+static int fts5_go_tokenize(Fts5Tokenizer* tok, void* ctx, int flags, const char* text, int n, fts5_token_fn xToken) {
+	return goFTS5Tokenize((uintptr_t)tok, ctx, (char*)text, n, (void*)xToken);
+}
+static void fts5_go_destroy(void* ctx) { goFTS5Destroy((uintptr_t)ctx); }
+static fts5_tokenizer fts5_go_tokenizer = { fts5_go_create, fts5_go_delete, fts5_go_tokenize };
+static inline int fts5_emit(void* xToken, void* ctx, const char* token, int n, int start, int end) {
+	return ((fts5_token_fn)xToken)(ctx, 0, token, n, start, end);
+}
+static inline int sqlite3_fts5_register(sqlite3* db, const char* name, uintptr_t factory) {
+	fts5_api* api = 0;
+	sqlite3_stmt* stmt;
+	int rc = sqlite3_prepare_v2(db, "SELECT fts5(?1)", -1, &stmt, 0);
+	if (rc != SQLITE_OK) return rc;
+	sqlite3_bind_pointer(stmt, 1, &api, "fts5_api_ptr", 0);
+	sqlite3_step(stmt);
+	rc = sqlite3_finalize(stmt);
+	if (rc != SQLITE_OK) return rc;
+	if (api == 0) return SQLITE_ERROR;
+	return api->xCreateTokenizer(api, name, (void*)factory, &fts5_go_tokenizer, fts5_go_destroy);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// A custom FTS5 tokenizer.
+type FTS5Tokenizer interface {
+	// Splits text into tokens, calling emit with each token and its byte offsets in text.
+	Tokenize(text string, emit func(token string, start, end int) error) error
+}
+
+// Registered tokenizer factories and the tokenizers they created.
+var fts5Handles handles[interface{}]
+
+// Registers an FTS5 tokenizer usable as tokenize='name ...' in CREATE VIRTUAL TABLE.
+// The factory is called with the tokenizer arguments for each table that uses it.
+func (db *Database) RegisterFTS5Tokenizer(name string, factory func(args []string) (FTS5Tokenizer, error)) error {
+	if !CompileOptionUsed("ENABLE_FTS5") {
+		return errors.New("FTS5 is not available")
+	}
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	id := fts5Handles.add(factory)
+	if s := C.sqlite3_fts5_register(db.db, cname, C.uintptr_t(id)); s != C.SQLITE_OK {
+		fts5Handles.remove(id)
+		return errors.New(C.GoString(C.sqlite3_errmsg(db.db)))
+	}
+	return nil
+}
+
+// Calls the factory with the handle to create a tokenizer for the arguments and returns the tokenizer's handle.
+func fts5Create(factory uintptr, argv **C.char, argc int) (uintptr, C.int) {
+	fn, _ := fts5Handles.get(factory).(func([]string) (FTS5Tokenizer, error))
+	if fn == nil {
+		return 0, C.SQLITE_ERROR
+	}
+	args := make([]string, argc)
+	for i, p := range unsafe.Slice(argv, argc) {
+		args[i] = C.GoString(p)
+	}
+	tok, err := fn(args)
+	if err != nil {
+		return 0, C.SQLITE_ERROR
+	}
+	return fts5Handles.add(tok), C.SQLITE_OK
+}
+
+// Tokenizes the text with the tokenizer with the handle, passing each token to FTS5's xToken callback.
+func fts5Tokenize(tok uintptr, ctx unsafe.Pointer, text *C.char, n int, xToken unsafe.Pointer) C.int {
+	t, _ := fts5Handles.get(tok).(FTS5Tokenizer)
+	if t == nil {
+		return C.SQLITE_ERROR
+	}
+	var rc C.int = C.SQLITE_OK
+	emit := func(token string, start, end int) error {
+		ctoken := C.CString(token)
+		rc = C.fts5_emit(xToken, ctx, ctoken, C.int(len(token)), C.int(start), C.int(end))
+		C.free(unsafe.Pointer(ctoken))
+		if rc != C.SQLITE_OK {
+			return errors.New(C.GoString(C.sqlite3_errstr(rc)))
+		}
+		return nil
+	}
+	if err := t.Tokenize(C.GoStringN(text, C.int(n)), emit); err != nil {
+		if rc != C.SQLITE_OK {
+			return rc
+		}
+		return C.SQLITE_ERROR
+	}
+	return C.SQLITE_OK
+}
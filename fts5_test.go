@@ -0,0 +1,61 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// A tokenizer splitting text on a separator given as its argument and lowercasing the tokens.
+type separatorTokenizer struct {
+	sep string
+}
+
+func (tok separatorTokenizer) Tokenize(text string, emit func(token string, start, end int) error) error {
+	start := 0
+	for _, part := range strings.Split(text, tok.sep) {
+		if part != "" {
+			if err := emit(strings.ToLower(part), start, start+len(part)); err != nil {
+				return err
+			}
+		}
+		start += len(part) + len(tok.sep)
+	}
+	return nil
+}
+
+func TestRegisterFTS5Tokenizer(t *testing.T) {
+	db := newTestDatabase(t)
+	var args []string
+	err := db.RegisterFTS5Tokenizer("separator", func(a []string) (FTS5Tokenizer, error) {
+		args = a
+		if len(a) != 1 {
+			return nil, errors.New("a separator is required")
+		}
+		return separatorTokenizer{a[0]}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExecute(t, db, "CREATE VIRTUAL TABLE tags USING fts5(list, tokenize = \"separator '|'\")")
+	if len(args) != 1 || args[0] != "|" {
+		t.Errorf("got tokenizer arguments %q, want [|]", args)
+	}
+	mustExecute(t, db, "INSERT INTO tags VALUES ('Red Wine|cheese'), ('red|white wine')")
+	for query, want := range map[string]int64{`"red wine"`: 1, "red": 1, "cheese": 1, `"white wine"`: 1, "wine": 0} {
+		n, err := db.queryInt64("SELECT count(*) FROM tags WHERE tags MATCH ?", query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Errorf("%s: got %d rows, want %d", query, n, want)
+		}
+	}
+	if err := db.Execute("CREATE VIRTUAL TABLE bad USING fts5(x, tokenize = 'separator')"); err == nil {
+		t.Error("creating a table with a failing tokenizer factory succeeded")
+	}
+}
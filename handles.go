@@ -0,0 +1,40 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import "sync"
+
+// Go values passed to C callbacks, keyed by handle, since C can't hold Go pointers.
+type handles[T any] struct {
+	sync.Mutex
+	values map[uintptr]T
+	next   uintptr
+}
+
+// Stores the value and returns its handle, which is never 0.
+func (h *handles[T]) add(v T) uintptr {
+	h.Lock()
+	defer h.Unlock()
+	if h.values == nil {
+		h.values = make(map[uintptr]T)
+	}
+	h.next++
+	h.values[h.next] = v
+	return h.next
+}
+
+// Returns the value with the handle, or the zero value if there's none.
+func (h *handles[T]) get(id uintptr) T {
+	h.Lock()
+	defer h.Unlock()
+	return h.values[id]
+}
+
+// Forgets the value with the handle.
+func (h *handles[T]) remove(id uintptr) {
+	h.Lock()
+	delete(h.values, id)
+	h.Unlock()
+}
@@ -0,0 +1,76 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <stdint.h>
+#include <sqlite3.h>
+extern int goCommitGuard(uintptr_t);
+static int commit_guard(void* ctx) { return goCommitGuard((uintptr_t)ctx); }
+static inline void sqlite3_commit_guard(sqlite3* db, uintptr_t h) { sqlite3_commit_hook(db, h ? commit_guard : 0, (void*)h); }
+*/
+import "C"
+
+// A commit guard and the error with which it last vetoed a commit.
+type commitGuard struct {
+	fn  func() error
+	err error // guarded by commitGuards' lock
+}
+
+// Installed commit guards.
+var commitGuards handles[*commitGuard]
+
+// Calls fn before each commit (nil to remove it). If it returns an error, the transaction
+// is rolled back instead and Tx.Commit returns the error. Other ways of committing
+// (e.g. executing COMMIT or an autocommit statement) only report that the commit failed.
+// The function mustn't use the connection.
+func (db *Database) SetCommitGuard(fn func() error) {
+	if db.guard != 0 {
+		C.sqlite3_commit_guard(db.db, 0)
+		commitGuards.remove(db.guard)
+		db.guard = 0
+	}
+	if fn == nil {
+		return
+	}
+	db.guard = commitGuards.add(&commitGuard{fn: fn})
+	C.sqlite3_commit_guard(db.db, C.uintptr_t(db.guard))
+}
+
+// Installs the commit guard again on a reopened connection.
+func (db *Database) restoreCommitGuard() {
+	if db.guard != 0 {
+		C.sqlite3_commit_guard(db.db, C.uintptr_t(db.guard))
+	}
+}
+
+// Returns and clears the error with which the commit guard last vetoed a commit.
+func (db *Database) takeGuardError() error {
+	g := commitGuards.get(db.guard)
+	if g == nil {
+		return nil
+	}
+	commitGuards.Lock()
+	defer commitGuards.Unlock()
+	err := g.err
+	g.err = nil
+	return err
+}
+
+// Runs the commit guard and returns non-zero to turn the commit into a rollback.
+func runCommitGuard(id uintptr) C.int {
+	g := commitGuards.get(id)
+	if g == nil {
+		return 0
+	}
+	err := g.fn()
+	commitGuards.Lock()
+	g.err = err
+	commitGuards.Unlock()
+	if err != nil {
+		return 1
+	}
+	return 0
+}
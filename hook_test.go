@@ -0,0 +1,44 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSetCommitGuard(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (name TEXT)")
+	veto := errors.New("vetoed")
+	calls := 0
+	db.SetCommitGuard(func() error {
+		if calls++; calls == 1 {
+			return veto
+		}
+		return nil
+	})
+	for i, want := range []error{veto, nil} {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		mustExecute(t, db, "INSERT INTO items VALUES ('alpha')")
+		if err := tx.Commit(); err != want {
+			t.Errorf("commit %d: got %v, want %v", i+1, err, want)
+		}
+		if db.InTransaction() {
+			t.Fatalf("commit %d left the transaction open", i+1)
+		}
+	}
+	if n, _ := db.queryInt64("SELECT count(*) FROM items"); n != 1 {
+		t.Errorf("got %d rows, want only the second commit's row", n)
+	}
+	db.SetCommitGuard(nil)
+	mustExecute(t, db, "INSERT INTO items VALUES ('beta')")
+	if calls != 2 {
+		t.Errorf("the guard was called %d times, want 2", calls)
+	}
+}
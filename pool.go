@@ -0,0 +1,153 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// A pool of connections to the same database file, opened on demand up to a maximum size.
+// Each connection is configured with the pool's options, including Options.PragmaHook.
+type Pool struct {
+	// How long Get waits for a connection when all are in use (0 for as long as the context allows).
+	// It's independent of the busy timeout of the connections.
+	GetTimeout time.Duration
+	// How long a statement run with Pool.Execute or Pool.Query may take before it's interrupted (0 for no limit).
+	// Statements run on connections obtained with Get aren't limited, ExecuteContext can limit them.
+	QueryTimeout time.Duration
+
+	path   string
+	opts   Options
+	idle   chan *Database
+	slots  chan struct{}
+	lock   sync.Mutex
+	closed bool
+}
+
+// Returns a new pool of at most size connections to the database file.
+func NewPool(path string, size int, opts *Options) (*Pool, error) {
+	if size <= 0 {
+		return nil, errors.New("the pool size must be positive")
+	}
+	if path == "" {
+		return nil, errors.New("a pool requires a database file")
+	}
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	return &Pool{path: path, opts: *opts, idle: make(chan *Database, size), slots: make(chan struct{}, size)}, nil
+}
+
+// Returns an idle connection, opening a new one if the pool isn't full,
+// or waits until one is returned with Put or the context is done.
+func (p *Pool) Get(ctx context.Context) (*Database, error) {
+	p.lock.Lock()
+	closed := p.closed
+	p.lock.Unlock()
+	if closed {
+		return nil, errors.New("pool closed")
+	}
+	select {
+	case db := <-p.idle:
+		return db, nil
+	default:
+	}
+	var timeout <-chan time.Time
+	if p.GetTimeout > 0 {
+		timer := time.NewTimer(p.GetTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case db := <-p.idle:
+		return db, nil
+	case p.slots <- struct{}{}:
+		opts := p.opts
+		db, err := Open(p.path, &opts)
+		if err != nil {
+			<-p.slots
+			return nil, err
+		}
+		return db, nil
+	case <-ctx.Done():
+		return nil, contextError(ctx.Err())
+	case <-timeout:
+		return nil, &TimeoutError{Op: "getting a connection"}
+	}
+}
+
+// Returns the connection to the pool. It's closed if the pool has been closed.
+func (p *Pool) Put(db *Database) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		db.Close()
+		<-p.slots
+		return
+	}
+	p.idle <- db
+}
+
+// Closes the idle connections. Connections still in use are closed when they're returned.
+func (p *Pool) Close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.closed = true
+	for {
+		select {
+		case db := <-p.idle:
+			db.Close()
+			<-p.slots
+		default:
+			return
+		}
+	}
+}
+
+// Executes the SQL statement on a pooled connection, interrupting it if it takes longer than
+// QueryTimeout or the context is done first. The connection is returned to the pool afterwards.
+func (p *Pool) Execute(ctx context.Context, sql string) error {
+	return p.run(ctx, sql, func(db *Database) error {
+		return db.Execute(sql)
+	})
+}
+
+// Runs the query on a pooled connection and calls fn with its rows, interrupting the query
+// if it takes longer than QueryTimeout or the context is done first. The rows are closed
+// and the connection is returned to the pool when fn returns.
+func (p *Pool) Query(ctx context.Context, sql string, fn func(rows *Rows) error, args ...interface{}) error {
+	return p.run(ctx, sql, func(db *Database) error {
+		rows, err := db.Query(sql, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		if err := fn(rows); err != nil {
+			return err
+		}
+		return rows.Err()
+	})
+}
+
+// Calls fn with a pooled connection, interrupting it if it takes longer than QueryTimeout
+// or the context is done first.
+func (p *Pool) run(ctx context.Context, sql string, fn func(db *Database) error) error {
+	db, err := p.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.Put(db)
+	if p.QueryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.QueryTimeout)
+		defer cancel()
+	}
+	return db.withContext(ctx, sql, func() error {
+		return fn(db)
+	})
+}
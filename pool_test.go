@@ -0,0 +1,125 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoolQueryTimeout(t *testing.T) {
+	p, err := NewPool(t.TempDir()+"/pool.db", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.QueryTimeout = 50 * time.Millisecond
+	start := time.Now()
+	err = p.Execute(context.Background(), endlessQuery)
+	if err == nil {
+		t.Fatal("an endless query wasn't interrupted")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("the query was interrupted after %v", d)
+	}
+	if err := p.Execute(context.Background(), "CREATE TABLE items (name TEXT)"); err != nil {
+		t.Errorf("the connection isn't usable after the timeout: %v", err)
+	}
+	err = p.Query(context.Background(), endlessQuery, func(rows *Rows) error {
+		for rows.Next() {
+		}
+		return nil
+	})
+	var terr *TimeoutError
+	if !errors.As(err, &terr) {
+		t.Fatalf("got %v from an endless pooled query, want a timeout error", err)
+	}
+	if err := p.Execute(context.Background(), "INSERT INTO items VALUES ('alpha'), ('beta')"); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	err = p.Query(context.Background(), "SELECT name FROM items WHERE name <> ?", func(rows *Rows) error {
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return err
+			}
+			names = append(names, name)
+		}
+		return nil
+	}, "beta")
+	if err != nil {
+		t.Errorf("the connection isn't usable after the query timeout: %v", err)
+	}
+	if len(names) != 1 || names[0] != "alpha" {
+		t.Errorf("got names %q, want [alpha]", names)
+	}
+}
+
+func TestPoolPragmaHook(t *testing.T) {
+	opts := DefaultOptions()
+	hooked := 0
+	opts.PragmaHook = func(db *Database) error {
+		hooked++
+		return db.Execute("PRAGMA cache_size = -1234")
+	}
+	p, err := NewPool(t.TempDir()+"/pool.db", 3, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	var conns []*Database
+	for i := 0; i < 3; i++ {
+		db, err := p.Get(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, db)
+		if n, err := db.queryInt64("PRAGMA cache_size"); err != nil || n != -1234 {
+			t.Errorf("connection %d: got cache size %d (%v), want -1234", i, n, err)
+		}
+	}
+	for _, db := range conns {
+		p.Put(db)
+	}
+	if hooked != 3 {
+		t.Errorf("the hook ran %d times, want once per connection", hooked)
+	}
+}
+
+func TestPoolGetTimeout(t *testing.T) {
+	p, err := NewPool(t.TempDir()+"/pool.db", 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.GetTimeout = 50 * time.Millisecond
+	var conns []*Database
+	for i := 0; i < 2; i++ {
+		db, err := p.Get(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, db)
+	}
+	start := time.Now()
+	_, err = p.Get(context.Background())
+	var terr *TimeoutError
+	if !errors.As(err, &terr) {
+		t.Fatalf("got %v from an exhausted pool, want a timeout error", err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond || d > 2*time.Second {
+		t.Errorf("Get gave up after %v, want about 50ms", d)
+	}
+	p.Put(conns[0])
+	db, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("got %v after a connection was returned", err)
+	}
+	p.Put(db)
+	p.Put(conns[1])
+}
@@ -0,0 +1,347 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <stdlib.h>
+#include <sqlite3.h>
+inline void sqlite3_set_temp_directory(const char* path) {
+	char* old = sqlite3_temp_directory;
+	sqlite3_temp_directory = path ? sqlite3_mprintf("%s", path) : 0;
+	sqlite3_free(old);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// Updates the query planner statistics if needed (PRAGMA optimize).
+func (db *Database) Optimize() error {
+	return db.Execute("PRAGMA optimize")
+}
+
+// Gathers query planner statistics for the table (or the whole database if empty).
+func (db *Database) Analyze(table string) error {
+	if table == "" {
+		return db.Execute("ANALYZE")
+	}
+	return db.Execute("ANALYZE " + QuoteIdentifier(table))
+}
+
+// Rebuilds the indexes using the collation, of the table, or the index itself (all indexes if empty).
+func (db *Database) Reindex(target string) error {
+	if target == "" {
+		return db.Execute("REINDEX")
+	}
+	return db.Execute("REINDEX " + QuoteIdentifier(target))
+}
+
+// Runs fn with foreign key enforcement disabled, then re-enables it and checks for violations.
+// Foreign key enforcement can't be changed inside a transaction so it mustn't be called in one.
+func (db *Database) WithoutForeignKeys(fn func() error) error {
+	if db.InTransaction() {
+		return errors.New("foreign keys can't be disabled inside a transaction")
+	}
+	if err := db.Execute("PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	err := fn()
+	if err2 := db.Execute("PRAGMA foreign_keys = ON"); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return err
+	}
+	stmt, err := db.NewStatement("PRAGMA foreign_key_check")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	var violations []string
+	if err := stmt.StepRows(func() {
+		violations = append(violations, fmt.Sprintf("%s row %d references %s", stmt.ColumnText(0), stmt.ColumnInt64(1), stmt.ColumnText(2)))
+	}); err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("foreign key violations: %s", strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// Returns the schema version, which SQLite increments on every schema change.
+func (db *Database) SchemaVersion() (int, error) {
+	v, err := db.queryInt64("PRAGMA schema_version")
+	return int(v), err
+}
+
+// Returns the query planner statistics (from sqlite_stat1) of the table's indexes keyed by index name,
+// where the table itself stands for a table without indexes. The result is empty if ANALYZE hasn't run.
+func (db *Database) StatsFor(table string) (map[string]string, error) {
+	stats := make(map[string]string)
+	n, err := db.queryInt64("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'sqlite_stat1'")
+	if err != nil || n == 0 {
+		return stats, err
+	}
+	stmt, err := db.NewStatement("SELECT coalesce(idx, tbl), stat FROM sqlite_stat1 WHERE tbl = ?")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	stmt.BindText(1, table)
+	if err := stmt.StepRows(func() {
+		stats[stmt.ColumnText(0)] = stmt.ColumnText(1)
+	}); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Enables or disables recursive triggers (disabled by default unless SQLite was compiled with
+// SQLITE_DEFAULT_RECURSIVE_TRIGGERS).
+func (db *Database) SetRecursiveTriggers(on bool) error {
+	if on {
+		return db.Execute("PRAGMA recursive_triggers = ON")
+	}
+	return db.Execute("PRAGMA recursive_triggers = OFF")
+}
+
+// Returns true if recursive triggers are enabled.
+func (db *Database) RecursiveTriggers() (bool, error) {
+	v, err := db.queryInt64("PRAGMA recursive_triggers")
+	return v != 0, err
+}
+
+// Where temporary tables and indices are stored.
+type TempStore int
+
+const (
+	// The compile-time default.
+	TempStoreDefault TempStore = iota
+	// In temporary files.
+	TempStoreFile
+	// In memory.
+	TempStoreMemory
+)
+
+// Sets where temporary tables and indices are stored (PRAGMA temp_store).
+func (db *Database) SetTempStore(mode TempStore) error {
+	return db.Execute(fmt.Sprintf("PRAGMA temp_store = %d", int(mode)))
+}
+
+// A secure_delete mode.
+type SecureDelete int
+
+const (
+	// Deleted content is left in the file.
+	SecureDeleteOff SecureDelete = iota
+	// Deleted content is overwritten with zeros.
+	SecureDeleteOn
+	// Deleted content is overwritten only if that doesn't increase I/O.
+	SecureDeleteFast
+)
+
+// Sets whether deleted content is overwritten (PRAGMA secure_delete).
+// Overwriting costs extra writes and I/O, especially when deleting large amounts of data.
+func (db *Database) SetSecureDelete(mode SecureDelete) error {
+	// Only the keyword selects FAST, numeric values are read as booleans.
+	switch mode {
+	case SecureDeleteOff:
+		return db.Execute("PRAGMA secure_delete = OFF")
+	case SecureDeleteOn:
+		return db.Execute("PRAGMA secure_delete = ON")
+	case SecureDeleteFast:
+		return db.Execute("PRAGMA secure_delete = FAST")
+	}
+	return fmt.Errorf("invalid secure_delete mode %d", int(mode))
+}
+
+// Returns the secure_delete mode.
+func (db *Database) SecureDelete() (SecureDelete, error) {
+	n, err := db.queryInt64("PRAGMA secure_delete")
+	return SecureDelete(n), err
+}
+
+// Sets the directory of temporary files for all connections (empty for the default).
+// It isn't thread-safe and should be called before any database is opened.
+func SetTempDirectory(path string) {
+	if path == "" {
+		C.sqlite3_set_temp_directory(nil)
+		return
+	}
+	cs := C.CString(path)
+	defer C.free(unsafe.Pointer(cs))
+	C.sqlite3_set_temp_directory(cs)
+}
+
+// Sets the size limit of the journal or WAL file left after a transaction (-1 for no limit)
+// and returns the applied value.
+func (db *Database) SetJournalSizeLimit(bytes int64) (int64, error) {
+	return db.queryInt64(fmt.Sprintf("PRAGMA journal_size_limit = %d", bytes))
+}
+
+// Sets the maximum number of pages of the main database and returns the applied value.
+// Writes that would grow the database beyond it fail with SQLITE_FULL.
+// The limit must be positive, and SQLite won't lower it below the current page count
+// or raise it above its own maximum, which are reported as errors.
+func (db *Database) SetMaxPageCount(pages int64) (int64, error) {
+	if pages <= 0 {
+		return 0, fmt.Errorf("max page count %d is not positive", pages)
+	}
+	n, err := db.queryInt64(fmt.Sprintf("PRAGMA max_page_count = %d", pages))
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case n > pages:
+		return n, fmt.Errorf("max page count %d is below the current page count %d", pages, n)
+	case n < pages:
+		return n, fmt.Errorf("max page count %d is above SQLite's maximum %d", pages, n)
+	}
+	return n, nil
+}
+
+// Returns the number of bytes used by each table and index in the main database.
+// It requires SQLite to be compiled with SQLITE_ENABLE_DBSTAT_VTAB.
+func (db *Database) TableSizes() (map[string]int64, error) {
+	if !CompileOptionUsed("ENABLE_DBSTAT_VTAB") {
+		return nil, errors.New("the dbstat virtual table is not available")
+	}
+	stmt, err := db.NewStatement("SELECT name, sum(pgsize) FROM dbstat('main') GROUP BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	sizes := make(map[string]int64)
+	if err := stmt.StepRows(func() {
+		sizes[stmt.ColumnText(0)] = stmt.ColumnInt64(1)
+	}); err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
+
+// Runs fn with the connection in exclusive locking mode and then restores the previous mode.
+// The exclusive mode takes effect on the next transaction, after which the connection keeps
+// its locks until the mode is restored and the database is accessed again.
+func (db *Database) WithExclusiveLock(fn func() error) error {
+	stmt, err := db.NewStatement("PRAGMA locking_mode")
+	if err != nil {
+		return err
+	}
+	var mode string
+	err = stmt.StepRows(func() {
+		mode = stmt.ColumnText(0)
+	})
+	stmt.Close()
+	if err != nil {
+		return err
+	}
+	if err := db.Execute("PRAGMA locking_mode = EXCLUSIVE"); err != nil {
+		return err
+	}
+	err = fn()
+	if err2 := db.Execute("PRAGMA locking_mode = " + mode); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Sets the journal mode and returns an error if SQLite keeps a different one
+// (e.g. WAL for in-memory databases).
+func (db *Database) setJournalMode(mode JournalMode) error {
+	stmt, err := db.NewStatement("PRAGMA journal_mode = " + string(mode))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	var applied string
+	if err := stmt.StepRows(func() {
+		applied = stmt.ColumnText(0)
+	}); err != nil {
+		return err
+	}
+	if !strings.EqualFold(applied, string(mode)) {
+		return fmt.Errorf("journal mode %s couldn't be set, the mode is %s", mode, applied)
+	}
+	return nil
+}
+
+// Runs fn with the journal mode temporarily set to mode and then restores the previous mode.
+// Modes other than the previous one change durability while fn runs: with OFF or MEMORY a crash
+// or an application failure in the middle of a transaction can corrupt the database.
+// Switching to or from WAL isn't possible inside a transaction so it mustn't be called in one then.
+func (db *Database) WithJournalMode(mode JournalMode, fn func() error) error {
+	stmt, err := db.NewStatement("PRAGMA journal_mode")
+	if err != nil {
+		return err
+	}
+	var prev string
+	err = stmt.StepRows(func() {
+		prev = stmt.ColumnText(0)
+	})
+	stmt.Close()
+	if err != nil {
+		return err
+	}
+	if db.InTransaction() && (strings.EqualFold(prev, string(JournalWAL)) || mode == JournalWAL) {
+		return errors.New("journal mode can't be switched to or from WAL inside a transaction")
+	}
+	if err := db.setJournalMode(mode); err != nil {
+		return err
+	}
+	err = fn()
+	if err2 := db.setJournalMode(JournalMode(strings.ToUpper(prev))); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Runs PRAGMA quick_check reporting at most maxErrors problems and returns them
+// (an empty slice if the database is fine). If the check fails partway, the problems found
+// before the failure are returned along with the error.
+func (db *Database) QuickCheckN(maxErrors int) ([]string, error) {
+	stmt, err := db.NewStatement(fmt.Sprintf("PRAGMA quick_check(%d)", maxErrors))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	problems := []string{}
+	if err := stmt.StepRows(func() {
+		// A row can hold several problems on separate lines, preceded by the schema they're in.
+		for _, s := range strings.Split(stmt.ColumnText(0), "\n") {
+			if s != "ok" && !strings.HasPrefix(s, "*** in database ") {
+				problems = append(problems, s)
+			}
+		}
+	}); err != nil {
+		return problems, errors.New(C.GoString(C.sqlite3_errmsg(db.db)))
+	}
+	return problems, nil
+}
+
+// Reads every page of the tables' b-trees so that they're in the page cache before they're queried.
+// Overflow pages of large values aren't read and the cache may be too small to hold everything.
+func (db *Database) WarmCache(tables []string) error {
+	for _, table := range tables {
+		// NOT INDEXED makes SQLite count the table's own b-tree rather than a smaller index.
+		if err := db.Drain("SELECT count(*) FROM " + QuoteIdentifier(table) + " NOT INDEXED"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the number of bytes of heap memory used by the connection's page cache.
+func (db *Database) CacheUsed() int {
+	var cur, hi C.int
+	C.sqlite3_db_status(db.db, C.SQLITE_DBSTATUS_CACHE_USED, &cur, &hi, 0)
+	return int(cur)
+}
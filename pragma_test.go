@@ -0,0 +1,440 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Returns the path of a database file whose pages 3 and 4 are overwritten with 0xFF.
+func corruptDatabase(t *testing.T) string {
+	t.Helper()
+	path := t.TempDir() + "/corrupt.db"
+	db, err := NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExecute(t, db, "PRAGMA page_size = 4096; CREATE TABLE a (x); CREATE TABLE b (x)")
+	mustExecute(t, db, "WITH RECURSIVE s(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM s WHERE n < 2000) INSERT INTO a SELECT randomblob(50) FROM s")
+	mustExecute(t, db, "INSERT INTO b SELECT x FROM a")
+	db.Close()
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(bytes.Repeat([]byte{0xFF}, 2*4096), 2*4096); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestQuickCheckN(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE a (x)")
+	problems, err := db.QuickCheckN(10)
+	if err != nil || len(problems) != 0 {
+		t.Errorf("got %v (%v) for a sound database, want no problems", problems, err)
+	}
+	corrupt, err := Open(corruptDatabase(t), &Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer corrupt.Close()
+	for _, max := range []int{1, 100} {
+		problems, err := corrupt.QuickCheckN(max)
+		if len(problems) == 0 || len(problems) > max {
+			t.Errorf("QuickCheckN(%d) returned %d problems (%v), want 1 to %d", max, len(problems), err, max)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, `CREATE TABLE "odd ""name""" (x INTEGER); CREATE INDEX odd_x ON "odd ""name""" (x); INSERT INTO "odd ""name""" VALUES (1), (2), (2)`)
+	if err := db.Analyze(`odd "name"`); err != nil {
+		t.Fatal(err)
+	}
+	n, err := db.queryInt64(`SELECT count(*) FROM sqlite_stat1 WHERE tbl = 'odd "name"' AND idx = 'odd_x'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d sqlite_stat1 rows for the index, want 1", n)
+	}
+	if err := db.Optimize(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWithoutForeignKeys(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, `PRAGMA foreign_keys = ON;
+		CREATE TABLE parents (id INTEGER PRIMARY KEY);
+		CREATE TABLE children (id INTEGER PRIMARY KEY, parent INTEGER REFERENCES parents (id))`)
+	if err := db.Execute("INSERT INTO children VALUES (1, 1)"); err == nil {
+		t.Fatal("foreign keys aren't enforced")
+	}
+	// Children are loaded before their parents.
+	err := db.WithoutForeignKeys(func() error {
+		return db.Execute("INSERT INTO children VALUES (1, 1); INSERT INTO parents VALUES (1)")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.WithoutForeignKeys(func() error {
+		return db.Execute("INSERT INTO children VALUES (2, 7)")
+	})
+	if err == nil || !strings.Contains(err.Error(), "children row 2 references parents") {
+		t.Errorf("got %v, want the violation", err)
+	}
+	if on, _ := db.queryInt64("PRAGMA foreign_keys"); on != 1 {
+		t.Error("foreign keys weren't re-enabled")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err := db.WithoutForeignKeys(func() error { return nil }); err == nil {
+		t.Error("disabling foreign keys inside a transaction succeeded")
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	db := newTestDatabase(t)
+	before, err := db.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExecute(t, db, "CREATE TABLE items (x)")
+	after, err := db.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after <= before {
+		t.Errorf("the schema version went from %d to %d, want an increment", before, after)
+	}
+}
+
+func TestReindex(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, `CREATE TABLE "odd table" (name TEXT COLLATE NOCASE); CREATE INDEX names ON "odd table" (name);
+		INSERT INTO "odd table" VALUES ('b'), ('A'), ('c')`)
+	for _, target := range []string{"", "odd table", "names", "NOCASE"} {
+		if err := db.Reindex(target); err != nil {
+			t.Errorf("reindexing %q: %v", target, err)
+		}
+	}
+	if err := db.Reindex("missing"); err == nil {
+		t.Error("reindexing a missing target succeeded")
+	}
+	stmt, err := db.NewStatement(`SELECT name FROM "odd table" INDEXED BY names ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	var got string
+	if err := stmt.StepRows(func() { got += stmt.ColumnText(0) }); err != nil {
+		t.Fatal(err)
+	}
+	if got != "Abc" {
+		t.Errorf("got order %q, want Abc", got)
+	}
+}
+
+func TestStatsFor(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (x INTEGER); CREATE INDEX items_x ON items (x); INSERT INTO items VALUES (1), (1), (2), (3)")
+	stats, err := db.StatsFor("items")
+	if err != nil || len(stats) != 0 {
+		t.Errorf("got %v (%v) before ANALYZE, want no stats", stats, err)
+	}
+	if err := db.Analyze(""); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = db.StatsFor("items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats["items_x"] != "4 2" {
+		t.Errorf("got stats %v, want 4 rows with 2 per key in items_x", stats)
+	}
+}
+
+func TestSetRecursiveTriggers(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, `CREATE TABLE countdown (n INTEGER);
+		CREATE TRIGGER decrement AFTER INSERT ON countdown WHEN NEW.n > 0
+		BEGIN INSERT INTO countdown VALUES (NEW.n - 1); END`)
+	for _, c := range []struct {
+		on   bool
+		rows int64
+	}{{false, 2}, {true, 4}} {
+		if err := db.SetRecursiveTriggers(c.on); err != nil {
+			t.Fatal(err)
+		}
+		if on, err := db.RecursiveTriggers(); err != nil || on != c.on {
+			t.Fatalf("got recursive triggers %v (%v), want %v", on, err, c.on)
+		}
+		mustExecute(t, db, "DELETE FROM countdown; INSERT INTO countdown VALUES (3)")
+		if n, _ := db.queryInt64("SELECT count(*) FROM countdown"); n != c.rows {
+			t.Errorf("recursive triggers %v: got %d rows, want %d", c.on, n, c.rows)
+		}
+	}
+}
+
+// A VFS refusing to open temporary files, which are the ones without a name.
+type noTempFilesVFS struct {
+	PassThroughVFS
+}
+
+func (noTempFilesVFS) Open(name string, flags int) error {
+	if name == "" {
+		return errors.New("no temporary files")
+	}
+	return nil
+}
+
+func TestSetTempStore(t *testing.T) {
+	if err := RegisterVFS("no_temp_files_test", noTempFilesVFS{}); err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	opts.VFS = "no_temp_files_test"
+	db, err := Open(t.TempDir()+"/temp.db", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// A small cache makes the sorter spill to temporary files unless they're kept in memory.
+	mustExecute(t, db, `PRAGMA cache_size = 10; CREATE TABLE items (data BLOB);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 20000)
+		INSERT INTO items SELECT randomblob(200) FROM n`)
+	sort := func() error {
+		stmt, err := db.NewStatement("SELECT data FROM items ORDER BY data")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		return stmt.StepRows(func() {})
+	}
+	if err := db.SetTempStore(TempStoreFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := sort(); err == nil {
+		t.Fatal("sorting succeeded although temporary files can't be opened")
+	}
+	if err := db.SetTempStore(TempStoreMemory); err != nil {
+		t.Fatal(err)
+	}
+	if err := sort(); err != nil {
+		t.Errorf("sorting in memory failed: %v", err)
+	}
+}
+
+func TestSetJournalSizeLimit(t *testing.T) {
+	db := newTestDatabase(t)
+	for _, limit := range []int64{1 << 20, 0, -1} {
+		applied, err := db.SetJournalSizeLimit(limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if applied != limit {
+			t.Errorf("got applied limit %d, want %d", applied, limit)
+		}
+		if n, err := db.queryInt64("PRAGMA journal_size_limit"); err != nil || n != limit {
+			t.Errorf("got limit %d (%v) read back, want %d", n, err, limit)
+		}
+	}
+}
+
+func TestTableSizes(t *testing.T) {
+	if !CompileOptionUsed("ENABLE_DBSTAT_VTAB") {
+		t.Skip("SQLite is compiled without SQLITE_ENABLE_DBSTAT_VTAB")
+	}
+	db := newTestDatabase(t)
+	mustExecute(t, db, `CREATE TABLE small (x); INSERT INTO small VALUES (1);
+		CREATE TABLE large (data BLOB); INSERT INTO large SELECT randomblob(100000)`)
+	sizes, err := db.TableSizes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sizes["small"] <= 0 || sizes["large"] < 100000 {
+		t.Errorf("got sizes %v, want small positive and large at least 100000", sizes)
+	}
+	if sizes["large"] <= sizes["small"] {
+		t.Errorf("the large table (%d bytes) isn't larger than the small one (%d bytes)", sizes["large"], sizes["small"])
+	}
+}
+
+func TestSetMaxPageCount(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, `CREATE TABLE items (data BLOB);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 50)
+		INSERT INTO items SELECT randomblob(1000) FROM n`)
+	for _, pages := range []int64{0, -1} {
+		if _, err := db.SetMaxPageCount(pages); err == nil || !strings.Contains(err.Error(), "not positive") {
+			t.Errorf("got %v setting the cap to %d, want a not positive error", err, pages)
+		}
+	}
+	current, err := db.queryInt64("PRAGMA page_count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := db.SetMaxPageCount(current - 1); err == nil || n != current {
+		t.Errorf("got %d (%v) lowering the cap below the current %d pages, want an error", n, err, current)
+	}
+	if n, err := db.SetMaxPageCount(current + 2); err != nil || n != current+2 {
+		t.Fatalf("got %d (%v), want the cap %d applied", n, err, current+2)
+	}
+	err = db.Execute("INSERT INTO items SELECT randomblob(100000)")
+	if err == nil || !strings.Contains(err.Error(), "full") {
+		t.Errorf("got %v inserting beyond the cap, want a database or disk is full error", err)
+	}
+	if n, _ := db.queryInt64("SELECT count(*) FROM items"); n != 50 {
+		t.Errorf("got %d rows after the failed insert, want 50", n)
+	}
+}
+
+func TestWithExclusiveLock(t *testing.T) {
+	path := t.TempDir() + "/exclusive.db"
+	db, err := NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	other, err := NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	mustExecute(t, db, "CREATE TABLE items (name TEXT)")
+	err = db.WithExclusiveLock(func() error {
+		if err := db.Execute("INSERT INTO items VALUES ('alpha'); INSERT INTO items VALUES ('beta')"); err != nil {
+			return err
+		}
+		// The lock is kept after the transactions.
+		if _, err := other.queryInt64("SELECT count(*) FROM items"); err == nil {
+			t.Error("another connection could read during the exclusive lock")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt, err := db.NewStatement("PRAGMA locking_mode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mode string
+	err = stmt.StepRows(func() { mode = stmt.ColumnText(0) })
+	stmt.Close()
+	if err != nil || mode != "normal" {
+		t.Errorf("got locking mode %q (%v), want normal", mode, err)
+	}
+	// The lock is released when the database is next accessed in normal mode.
+	if n, err := db.queryInt64("SELECT count(*) FROM items"); err != nil || n != 2 {
+		t.Fatalf("got %d rows (%v), want 2", n, err)
+	}
+	if n, err := other.queryInt64("SELECT count(*) FROM items"); err != nil || n != 2 {
+		t.Errorf("got %d rows (%v) from another connection after restoring the mode, want 2", n, err)
+	}
+}
+
+func TestSetSecureDelete(t *testing.T) {
+	for _, mode := range []SecureDelete{SecureDeleteOn, SecureDeleteFast, SecureDeleteOff} {
+		path := t.TempDir() + "/secure.db"
+		db, err := NewDatabase(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.SetSecureDelete(mode); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := db.SecureDelete(); err != nil || got != mode {
+			t.Errorf("got mode %d (%v) read back, want %d", got, err, mode)
+		}
+		mustExecute(t, db, "CREATE TABLE secrets (s TEXT); INSERT INTO secrets VALUES ('hunter2-secret'), ('other')")
+		mustExecute(t, db, "DELETE FROM secrets WHERE s = 'hunter2-secret'")
+		db.Close()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if left := bytes.Contains(data, []byte("hunter2-secret")); left != (mode == SecureDeleteOff) {
+			t.Errorf("mode %d: deleted content left in the file: %v", mode, left)
+		}
+	}
+}
+
+func TestWarmCache(t *testing.T) {
+	path := t.TempDir() + "/warm.db"
+	db, err := NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExecute(t, db, "CREATE TABLE a (x)")
+	mustExecute(t, db, "WITH RECURSIVE s(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM s WHERE n < 2000) INSERT INTO a SELECT randomblob(100) FROM s")
+	db.Close()
+	db, err = NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	before := db.CacheUsed()
+	if err := db.WarmCache([]string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if after := db.CacheUsed(); after < before+200000 {
+		t.Errorf("cache used grew from %d to %d bytes, want at least 200000 more", before, after)
+	}
+	if err := db.WarmCache([]string{"missing"}); err == nil {
+		t.Error("warming a missing table succeeded")
+	}
+}
+
+func TestWithJournalMode(t *testing.T) {
+	db, err := Open(t.TempDir()+"/journal.db", &Options{JournalMode: JournalWAL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mode := func() string {
+		t.Helper()
+		stmt, err := db.NewStatement("PRAGMA journal_mode")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		var mode string
+		if err := stmt.StepRows(func() { mode = stmt.ColumnText(0) }); err != nil {
+			t.Fatal(err)
+		}
+		return mode
+	}
+	errFailed := errors.New("failed")
+	err = db.WithJournalMode(JournalOff, func() error {
+		if m := mode(); m != "off" {
+			t.Errorf("got journal mode %s inside fn, want off", m)
+		}
+		return errFailed
+	})
+	if err != errFailed {
+		t.Errorf("got %v, want fn's error", err)
+	}
+	if m := mode(); m != "wal" {
+		t.Errorf("got journal mode %s afterwards, want wal", m)
+	}
+	mustExecute(t, db, "BEGIN")
+	defer mustExecute(t, db, "ROLLBACK")
+	if err := db.WithJournalMode(JournalOff, func() error { return nil }); err == nil {
+		t.Error("switching from WAL inside a transaction succeeded")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// The kind of a file as determined by ProbeFile.
+type FileKind int
+
+const (
+	// An SQLite database.
+	ValidSQLite FileKind = iota
+	// A file that isn't an SQLite database (e.g. encrypted or unrelated).
+	NotSQLite
+	// An empty file (which SQLite would open as a new database).
+	Empty
+)
+
+// The magic string at the start of every SQLite database file.
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// Determines whether the file is an SQLite database by reading its header.
+func ProbeFile(path string) (FileKind, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return NotSQLite, err
+	}
+	defer f.Close()
+	header := make([]byte, len(sqliteMagic))
+	n, err := io.ReadFull(f, header)
+	if n == 0 && (err == io.EOF || err == nil) {
+		return Empty, nil
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return NotSQLite, err
+	}
+	if n == len(sqliteMagic) && bytes.Equal(header, sqliteMagic) {
+		return ValidSQLite, nil
+	}
+	return NotSQLite, nil
+}
+
+// Checks that the file (e.g. a finished backup) is an SQLite database that passes PRAGMA quick_check.
+// The file is opened read-only, so verifying it never modifies it.
+func VerifyBackup(path string) error {
+	kind, err := ProbeFile(path)
+	if err != nil {
+		return err
+	}
+	if kind != ValidSQLite {
+		return fmt.Errorf("%s is not an SQLite database", path)
+	}
+	db, err := Open(path, &Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	stmt, err := db.NewStatement("PRAGMA quick_check")
+	if err != nil {
+		return fmt.Errorf("%s failed the quick check: %v", path, err)
+	}
+	defer stmt.Close()
+	var problems []string
+	cerr := stmt.StepRows(func() {
+		if s := stmt.ColumnText(0); s != "ok" {
+			problems = append(problems, s)
+		}
+	})
+	if len(problems) > 0 {
+		return fmt.Errorf("%s failed the quick check: %s", path, strings.Join(problems, "; "))
+	}
+	if cerr != nil {
+		return fmt.Errorf("%s failed the quick check: %s", path, C.GoString(C.sqlite3_errmsg(db.db)))
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestProbeFile(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewDatabase(dir + "/valid.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExecute(t, db, "CREATE TABLE items (name TEXT)")
+	db.Close()
+	if err := os.WriteFile(dir+"/notes.txt", []byte("just some text, long enough for a header"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/empty.db", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]FileKind{"valid.db": ValidSQLite, "notes.txt": NotSQLite, "empty.db": Empty} {
+		kind, err := ProbeFile(dir + "/" + name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if kind != want {
+			t.Errorf("%s: got kind %d, want %d", name, kind, want)
+		}
+	}
+	if _, err := ProbeFile(dir + "/missing.db"); err == nil {
+		t.Error("probing a missing file succeeded")
+	}
+}
+
+func TestVerifyBackup(t *testing.T) {
+	dir := t.TempDir()
+	db := newTestDatabase(t)
+	mustExecute(t, db, `CREATE TABLE items (data BLOB);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 100)
+		INSERT INTO items SELECT randomblob(1000) FROM n`)
+	if err := db.ExportTo(dir+"/backup.db", nil); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(dir + "/backup.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyBackup(dir + "/backup.db"); err != nil {
+		t.Errorf("a good backup failed verification: %v", err)
+	}
+	if after, _ := os.ReadFile(dir + "/backup.db"); !bytes.Equal(before, after) {
+		t.Error("verifying the backup modified it")
+	}
+	if err := os.WriteFile(dir+"/truncated.db", before[:len(before)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyBackup(dir + "/truncated.db"); err == nil {
+		t.Error("a truncated backup passed verification")
+	}
+	if err := VerifyBackup(dir + "/missing.db"); err == nil {
+		t.Error("a missing backup passed verification")
+	}
+}
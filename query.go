@@ -0,0 +1,319 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Returns true if the table has no rows.
+func (db *Database) TableEmpty(table string) (bool, error) {
+	stmt, err := db.NewStatement("SELECT 1 FROM " + QuoteIdentifier(table) + " LIMIT 1")
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+	switch C.sqlite3_step(stmt.stmt) {
+	case C.SQLITE_ROW:
+		return false, nil
+	case C.SQLITE_DONE:
+		return true, nil
+	default:
+		return false, errors.New(C.GoString(C.sqlite3_errmsg(db.db)))
+	}
+}
+
+// Deletes at most limit rows matching the WHERE clause and returns the number of deleted rows.
+// Requires SQLite compiled with SQLITE_ENABLE_UPDATE_DELETE_LIMIT.
+func (db *Database) DeleteBatch(table, whereClause string, limit int, args ...interface{}) (int64, error) {
+	if !CompileOptionUsed("ENABLE_UPDATE_DELETE_LIMIT") {
+		return 0, errors.New("DELETE ... LIMIT requires SQLite compiled with SQLITE_ENABLE_UPDATE_DELETE_LIMIT")
+	}
+	stmt, err := db.NewStatement("DELETE FROM " + QuoteIdentifier(table) + " WHERE " + whereClause + " LIMIT ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	if err := stmt.ExecArgs(append(args, limit)...); err != nil {
+		return 0, err
+	}
+	return db.Changes(), nil
+}
+
+// Runs the query and encodes each row as []interface{} using the encoder (e.g. gob or JSON).
+func (db *Database) QueryEncode(enc interface{ Encode(interface{}) error }, sql string, args ...interface{}) error {
+	stmt, err := db.NewStatement(sql)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	if err := stmt.BindAll(args...); err != nil {
+		return err
+	}
+	n := stmt.ColumnCount()
+	if serr := stmt.StepRowsWhile(func() bool {
+		row := make([]interface{}, n)
+		for i := range row {
+			row[i] = stmt.ColumnValue(i)
+		}
+		err = enc.Encode(row)
+		return err == nil
+	}); serr != nil {
+		return serr
+	}
+	return err
+}
+
+// Runs the query and discards all rows.
+func (db *Database) Drain(sql string, args ...interface{}) error {
+	stmt, err := db.NewStatement(sql)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	if err := stmt.BindAll(args...); err != nil {
+		return err
+	}
+	return stmt.StepRows(func() {})
+}
+
+// Checks that the SQL statement compiles (syntax and referenced objects) without running it.
+func (db *Database) Validate(sql string) error {
+	stmt, err := db.NewStatement(sql)
+	if err != nil {
+		return err
+	}
+	stmt.Close()
+	return nil
+}
+
+// The maximum number of values bound in a single IN list by FetchIDs.
+const fetchIDsChunkSize = 500
+
+// Returns the rowids of the rows whose column equals one of the values, keyed by the column value
+// as returned by ColumnValue (blobs are converted to strings). A value matching several rows
+// maps to one of them. Long lists are split into queries of up to 500 values.
+func (db *Database) FetchIDs(table, column string, values []interface{}) (map[interface{}]int64, error) {
+	ids := make(map[interface{}]int64, len(values))
+	for len(values) > 0 {
+		chunk := values
+		if len(chunk) > fetchIDsChunkSize {
+			chunk = chunk[:fetchIDsChunkSize]
+		}
+		values = values[len(chunk):]
+		sql := "SELECT " + QuoteIdentifier(column) + ", rowid FROM " + QuoteIdentifier(table) +
+			" WHERE " + QuoteIdentifier(column) + " IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(chunk)), ", ") + ")"
+		stmt, err := db.NewStatement(sql)
+		if err != nil {
+			return nil, err
+		}
+		if err := stmt.BindAll(chunk...); err != nil {
+			stmt.Close()
+			return nil, err
+		}
+		err = stmt.StepRows(func() {
+			key := stmt.ColumnValue(0)
+			if b, ok := key.([]byte); ok {
+				key = string(b)
+			}
+			ids[key] = stmt.ColumnInt64(1)
+		})
+		stmt.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// The number of rows StreamInto inserts per transaction.
+const streamBatchSize = 1000
+
+// Runs the query and inserts each row into the table of the destination database,
+// committing every 1000 rows, and returns the number of rows copied.
+// If it fails, the rows copied in earlier batches remain in the destination and their number is returned.
+func (src *Database) StreamInto(dst *Database, destTable, sql string, args ...interface{}) (int64, error) {
+	stmt, err := src.NewStatement(sql)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	if err := stmt.BindAll(args...); err != nil {
+		return 0, err
+	}
+	n := stmt.ColumnCount()
+	ins, err := dst.NewStatement("INSERT INTO " + QuoteIdentifier(destTable) + " VALUES (" + strings.TrimSuffix(strings.Repeat("?, ", n), ", ") + ")")
+	if err != nil {
+		return 0, err
+	}
+	defer ins.Close()
+	var tx *Tx
+	var copied, committed int64
+	serr := stmt.StepRowsWhile(func() bool {
+		if tx == nil {
+			if tx, err = dst.Begin(); err != nil {
+				return false
+			}
+		}
+		ins.Reset()
+		for i := 0; i < n; i++ {
+			if err = ins.Bind(i+1, stmt.ColumnValue(i)); err != nil {
+				return false
+			}
+		}
+		if err = ins.Step(); err != nil {
+			return false
+		}
+		copied++
+		if copied%streamBatchSize == 0 {
+			if err = tx.Commit(); err != nil {
+				return false
+			}
+			tx, committed = nil, copied
+		}
+		return true
+	})
+	if err == nil {
+		err = serr
+	}
+	if err == nil && tx != nil {
+		if err = tx.Commit(); err == nil {
+			committed = copied
+		}
+	}
+	// A failed COMMIT (e.g. SQLITE_BUSY) leaves the transaction open.
+	if err != nil && tx != nil && dst.InTransaction() {
+		tx.Rollback()
+	}
+	return committed, err
+}
+
+// Returns the number of rows the query would return by counting them in a subquery.
+// The count is exact at the time it's taken but rows may change before the query itself runs,
+// and counting still has to evaluate the whole query, although without materializing rows.
+func (db *Database) EstimateRows(sql string, args ...interface{}) (int64, error) {
+	return db.queryInt64("SELECT count(*) FROM ("+strings.TrimRight(strings.TrimSpace(sql), ";")+")", args...)
+}
+
+// Runs the query and returns its first column as a slice of int64, float64, string or []byte.
+// Every value must have the matching storage class (integers are also accepted for float64).
+func QueryColumn[T any](db *Database, sql string, args ...interface{}) ([]T, error) {
+	stmt, err := db.NewStatement(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	if err := stmt.BindAll(args...); err != nil {
+		return nil, err
+	}
+	vals := []T{}
+	var verr error
+	serr := stmt.StepRowsWhile(func() bool {
+		var v interface{}
+		t := C.sqlite3_column_type(stmt.stmt, 0)
+		switch any(*new(T)).(type) {
+		case int64:
+			if t == C.SQLITE_INTEGER {
+				v = stmt.ColumnInt64(0)
+			}
+		case float64:
+			if t == C.SQLITE_FLOAT || t == C.SQLITE_INTEGER {
+				v = stmt.ColumnDouble(0)
+			}
+		case string:
+			if t == C.SQLITE_TEXT {
+				v = stmt.ColumnText(0)
+			}
+		case []byte:
+			if t == C.SQLITE_BLOB {
+				v = stmt.ColumnBlob(0)
+			}
+		default:
+			verr = fmt.Errorf("unsupported column type %T", *new(T))
+			return false
+		}
+		if v == nil {
+			verr = &RowError{Index: len(vals), Err: fmt.Errorf("can't read %s value as %T", storageClass(t), *new(T))}
+			return false
+		}
+		vals = append(vals, v.(T))
+		return true
+	})
+	if verr != nil {
+		return nil, verr
+	}
+	if serr != nil {
+		return nil, serr
+	}
+	return vals, nil
+}
+
+// Returns the name of the storage class.
+func storageClass(t C.int) string {
+	switch t {
+	case C.SQLITE_INTEGER:
+		return "INTEGER"
+	case C.SQLITE_FLOAT:
+		return "REAL"
+	case C.SQLITE_TEXT:
+		return "TEXT"
+	case C.SQLITE_BLOB:
+		return "BLOB"
+	}
+	return "NULL"
+}
+
+// Returns the current row as a JSON object keyed by column name.
+// NULLs are encoded as null and blobs as base64 strings.
+func (stmt *Statement) RowJSON() ([]byte, error) {
+	row := make(map[string]interface{}, stmt.ColumnCount())
+	for i, name := range stmt.Header() {
+		row[name] = stmt.ColumnValue(i)
+	}
+	return json.Marshal(row)
+}
+
+// Runs the query and returns the statement positioned on its only row.
+// Returns ErrNoRows or ErrMultipleRows if the query doesn't return exactly one row.
+// The query is run twice because checking for a second row moves past the first one.
+func (db *Database) QueryExactlyOne(sql string, args ...interface{}) (*Statement, error) {
+	stmt, err := db.NewStatement(sql)
+	if err != nil {
+		return nil, err
+	}
+	if err := stmt.BindAll(args...); err != nil {
+		stmt.Close()
+		return nil, err
+	}
+	for _, want := range []C.int{C.SQLITE_ROW, C.SQLITE_DONE} {
+		if s := C.sqlite3_step(stmt.stmt); s != want {
+			stmt.Close()
+			switch s {
+			case C.SQLITE_DONE:
+				return nil, ErrNoRows
+			case C.SQLITE_ROW:
+				return nil, ErrMultipleRows
+			}
+			return nil, errors.New(C.GoString(C.sqlite3_errmsg(db.db)))
+		}
+	}
+	C.sqlite3_reset(stmt.stmt)
+	if s := C.sqlite3_step(stmt.stmt); s != C.SQLITE_ROW {
+		stmt.Close()
+		if s == C.SQLITE_DONE {
+			return nil, ErrNoRows
+		}
+		return nil, errors.New(C.GoString(C.sqlite3_errmsg(db.db)))
+	}
+	return stmt, nil
+}
@@ -0,0 +1,283 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamIntoCommitVetoed(t *testing.T) {
+	src := newTestDatabase(t)
+	createNums(t, src, 2500)
+	dst := newTestDatabase(t)
+	mustExecute(t, dst, "CREATE TABLE nums (n INTEGER)")
+	veto := errors.New("vetoed")
+	commits := 0
+	dst.SetCommitGuard(func() error {
+		if commits++; commits == 2 {
+			return veto
+		}
+		return nil
+	})
+	n, err := src.StreamInto(dst, "nums", "SELECT n FROM nums ORDER BY n")
+	if err != veto {
+		t.Fatalf("got error %v, want the veto", err)
+	}
+	if n != streamBatchSize {
+		t.Errorf("got %d rows copied, want %d", n, streamBatchSize)
+	}
+	if dst.InTransaction() {
+		t.Error("destination left in a transaction")
+	}
+	if rows, _ := dst.queryInt64("SELECT count(*) FROM nums"); rows != n {
+		t.Errorf("destination has %d rows, want %d", rows, n)
+	}
+}
+
+func TestStreamIntoCopiesAll(t *testing.T) {
+	src := newTestDatabase(t)
+	createNums(t, src, 2500)
+	dst := newTestDatabase(t)
+	mustExecute(t, dst, "CREATE TABLE nums (n INTEGER)")
+	n, err := src.StreamInto(dst, "nums", "SELECT n FROM nums")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows, _ := dst.queryInt64("SELECT count(*) FROM nums"); n != 2500 || rows != 2500 {
+		t.Errorf("got %d rows copied and %d in the destination, want 2500", n, rows)
+	}
+}
+
+func TestTableEmpty(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, `CREATE TABLE "order" (x)`)
+	empty, err := db.TableEmpty("order")
+	if err != nil || !empty {
+		t.Errorf("got %v (%v) for a new table, want true", empty, err)
+	}
+	mustExecute(t, db, `INSERT INTO "order" VALUES (1)`)
+	empty, err = db.TableEmpty("order")
+	if err != nil || empty {
+		t.Errorf("got %v (%v) after an insert, want false", empty, err)
+	}
+}
+
+// Creates the table nums with the integers from 1 to n.
+func createNums(t *testing.T, db *Database, n int) {
+	t.Helper()
+	mustExecute(t, db, fmt.Sprintf("CREATE TABLE nums (n INTEGER); WITH RECURSIVE s(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM s WHERE n < %d) INSERT INTO nums SELECT n FROM s", n))
+}
+
+func TestDeleteBatch(t *testing.T) {
+	if !CompileOptionUsed("ENABLE_UPDATE_DELETE_LIMIT") {
+		t.Skip("SQLite is compiled without SQLITE_ENABLE_UPDATE_DELETE_LIMIT")
+	}
+	db := newTestDatabase(t)
+	createNums(t, db, 25)
+	var counts []int64
+	for {
+		n, err := db.DeleteBatch("nums", "n > ?", 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+		counts = append(counts, n)
+	}
+	if fmt.Sprint(counts) != "[10 10 5]" {
+		t.Errorf("got batches %v, want [10 10 5]", counts)
+	}
+}
+
+func TestQueryEncodeGob(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (id INTEGER, price REAL, name TEXT, data BLOB); INSERT INTO items VALUES (1, 2.5, 'alpha', x'0102'), (2, NULL, 'beta', NULL)")
+	var buf bytes.Buffer
+	if err := db.QueryEncode(gob.NewEncoder(&buf), "SELECT * FROM items ORDER BY id"); err != nil {
+		t.Fatal(err)
+	}
+	dec := gob.NewDecoder(&buf)
+	want := [][]interface{}{{int64(1), 2.5, "alpha", []byte{1, 2}}, {int64(2), nil, "beta", nil}}
+	for i, w := range want {
+		var row []interface{}
+		if err := dec.Decode(&row); err != nil {
+			t.Fatalf("row %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(row, w) {
+			t.Errorf("row %d: got %#v, want %#v", i, row, w)
+		}
+	}
+}
+
+func TestDrain(t *testing.T) {
+	db := newTestDatabase(t)
+	createNums(t, db, 100)
+	if err := db.Drain("SELECT n FROM nums WHERE n > ?", 10); err != nil {
+		t.Error(err)
+	}
+	if err := db.Drain("SELECT nope FROM nums"); err == nil {
+		t.Error("draining an invalid query succeeded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (x)")
+	if err := db.Validate("INSERT INTO items VALUES (1)"); err != nil {
+		t.Error(err)
+	}
+	if n, _ := db.queryInt64("SELECT count(*) FROM items"); n != 0 {
+		t.Error("validating a statement ran it")
+	}
+	if err := db.Validate("SELECT * FROM missing"); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("got %v, want an error mentioning the table", err)
+	}
+}
+
+func TestFetchIDs(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, `CREATE TABLE items (code TEXT);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 200)
+		INSERT INTO items SELECT 'code ' || i FROM n`)
+	values := make([]interface{}, 100)
+	for i := range values {
+		values[i] = fmt.Sprintf("code %d", 2*i+1)
+	}
+	// Fast statements take 0ns at SQLite's millisecond resolution, a negative threshold logs them too.
+	queries := 0
+	db.SetSlowQueryLog(-1, func(sql string, d time.Duration) {
+		if strings.Contains(sql, " IN (") {
+			queries++
+		}
+	})
+	ids, err := db.FetchIDs("items", "code", values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queries != 1 {
+		t.Errorf("got %d queries, want 1", queries)
+	}
+	if len(ids) != 100 {
+		t.Fatalf("got %d ids, want 100", len(ids))
+	}
+	for i, v := range values {
+		if id := ids[v]; id != int64(2*i+1) {
+			t.Errorf("got rowid %d for %v, want %d", id, v, 2*i+1)
+		}
+	}
+}
+
+func TestEstimateRows(t *testing.T) {
+	db := newTestDatabase(t)
+	createNums(t, db, 1000)
+	for _, c := range []struct {
+		sql  string
+		args []interface{}
+		want int64
+	}{
+		{"SELECT * FROM nums", nil, 1000},
+		{"SELECT n FROM nums WHERE n % ? = 0;", []interface{}{10}, 100},
+		{"SELECT n FROM nums WHERE n > 2000", nil, 0},
+	} {
+		n, err := db.EstimateRows(c.sql, c.args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != c.want {
+			t.Errorf("%s: got %d rows, want %d", c.sql, n, c.want)
+		}
+	}
+}
+
+func TestQueryColumn(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (id INTEGER, price REAL, name TEXT, data BLOB); INSERT INTO items VALUES (1, 1.5, 'alpha', x'01'), (2, 2, 'beta', x'0203')")
+	ids, err := QueryColumn[int64](db, "SELECT id FROM items WHERE id > ? ORDER BY id", 0)
+	if err != nil || !reflect.DeepEqual(ids, []int64{1, 2}) {
+		t.Errorf("got ids %v (%v), want [1 2]", ids, err)
+	}
+	prices, err := QueryColumn[float64](db, "SELECT price FROM items ORDER BY id")
+	if err != nil || !reflect.DeepEqual(prices, []float64{1.5, 2}) {
+		t.Errorf("got prices %v (%v), want [1.5 2]", prices, err)
+	}
+	names, err := QueryColumn[string](db, "SELECT name FROM items ORDER BY id")
+	if err != nil || !reflect.DeepEqual(names, []string{"alpha", "beta"}) {
+		t.Errorf("got names %v (%v), want [alpha beta]", names, err)
+	}
+	blobs, err := QueryColumn[[]byte](db, "SELECT data FROM items ORDER BY id")
+	if err != nil || !reflect.DeepEqual(blobs, [][]byte{{1}, {2, 3}}) {
+		t.Errorf("got blobs %v (%v), want [[1] [2 3]]", blobs, err)
+	}
+	if empty, err := QueryColumn[int64](db, "SELECT id FROM items WHERE id > 5"); err != nil || empty == nil || len(empty) != 0 {
+		t.Errorf("got %#v (%v) for no rows, want an empty slice", empty, err)
+	}
+	if _, err := QueryColumn[int64](db, "SELECT name FROM items"); err == nil {
+		t.Error("scanning text into int64 succeeded")
+	}
+	if _, err := QueryColumn[bool](db, "SELECT id FROM items"); err == nil {
+		t.Error("an unsupported type succeeded")
+	}
+}
+
+func TestRowJSON(t *testing.T) {
+	db := newTestDatabase(t)
+	stmt, err := db.NewStatement("SELECT 42 AS i, 1.5 AS f, 'text' AS s, NULL AS n, x'0102' AS b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	var data []byte
+	var jerr error
+	if err := stmt.StepRows(func() { data, jerr = stmt.RowJSON() }); err != nil {
+		t.Fatal(err)
+	}
+	if jerr != nil {
+		t.Fatal(jerr)
+	}
+	var row map[string]interface{}
+	if err := json.Unmarshal(data, &row); err != nil {
+		t.Fatalf("%v in %s", err, data)
+	}
+	want := map[string]interface{}{"i": 42.0, "f": 1.5, "s": "text", "n": nil, "b": "AQI="}
+	if len(row) != len(want) {
+		t.Errorf("got %s, want the keys i, f, s, n and b", data)
+	}
+	for k, v := range want {
+		if got, ok := row[k]; !ok || got != v {
+			t.Errorf("got %v (%T) for %s, want %v (%T)", got, got, k, v, v)
+		}
+	}
+}
+
+func TestQueryExactlyOne(t *testing.T) {
+	db := newTestDatabase(t)
+	createNums(t, db, 3)
+	stmt, err := db.QueryExactlyOne("SELECT n * 10 FROM nums WHERE n = ?", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := stmt.ColumnInt64(0); n != 20 {
+		t.Errorf("got %d, want 20", n)
+	}
+	stmt.Close()
+	if _, err := db.QueryExactlyOne("SELECT n FROM nums WHERE n > ?", 3); err != ErrNoRows {
+		t.Errorf("got %v for no rows, want ErrNoRows", err)
+	}
+	if _, err := db.QueryExactlyOne("SELECT n FROM nums WHERE n > ?", 1); err != ErrMultipleRows {
+		t.Errorf("got %v for two rows, want ErrMultipleRows", err)
+	}
+	if _, err := db.QueryExactlyOne("SELECT n FROM missing"); err == nil || err == ErrNoRows {
+		t.Errorf("got %v for a missing table, want a prepare error", err)
+	}
+}
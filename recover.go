@@ -0,0 +1,142 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"math"
+	"strings"
+)
+
+// Copies the rows that can still be read from a damaged database into a new database
+// and returns the number of rows recovered. The schema must be readable.
+// Rows of rowid tables are read in rowid order and after an error the scan resumes past
+// the damaged range, so rows on damaged pages are lost but later ones are kept.
+// A WITHOUT ROWID table is only read up to the first error. Virtual tables aren't recovered.
+// It doesn't use SQLite's recover extension, which isn't available in all builds.
+// The damaged database is opened read-only, so it must exist and is never modified.
+func RecoverDatabase(corruptPath, outputPath string) (int64, error) {
+	src, err := Open(corruptPath, &Options{ReadOnly: true})
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	objs, err := src.schemaObjects()
+	if err != nil {
+		return 0, err
+	}
+	dst, err := Open(outputPath, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+	var total int64
+	for _, obj := range objs {
+		if obj.typ != "table" || strings.HasPrefix(strings.ToUpper(obj.sql), "CREATE VIRTUAL") {
+			continue
+		}
+		if err := dst.Execute(obj.sql); err != nil {
+			return total, err
+		}
+		n, err := recoverTable(src, dst, obj.name)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	for _, obj := range objs {
+		if obj.typ != "table" {
+			if err := dst.Execute(obj.sql); err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, nil
+}
+
+// Copies the readable rows of the table and returns their number.
+// Only errors of the destination database are returned.
+func recoverTable(src, dst *Database, table string) (int64, error) {
+	cols, err := src.TableInfo(table)
+	if err != nil {
+		return 0, err
+	}
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = QuoteIdentifier(col.Name)
+	}
+	// Tables without a rowid can't be read from a given position.
+	withRowid := src.Validate("SELECT rowid FROM "+QuoteIdentifier(table)) == nil
+	var sel, ins string
+	if withRowid {
+		sel = "SELECT rowid, " + strings.Join(names, ", ") + " FROM " + QuoteIdentifier(table) + " WHERE rowid > ? ORDER BY rowid"
+		ins = "INSERT INTO " + QuoteIdentifier(table) + " (rowid, " + strings.Join(names, ", ") + ") VALUES (?" + strings.Repeat(", ?", len(names)) + ")"
+	} else {
+		sel = "SELECT " + strings.Join(names, ", ") + " FROM " + QuoteIdentifier(table)
+		ins = "INSERT INTO " + QuoteIdentifier(table) + " VALUES (" + strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ") + ")"
+	}
+	stmt, err := src.NewStatement(sel)
+	if err != nil {
+		// The table can't be read at all.
+		return 0, nil
+	}
+	defer stmt.Close()
+	insStmt, err := dst.NewStatement(ins)
+	if err != nil {
+		return 0, err
+	}
+	defer insStmt.Close()
+	tx, err := dst.Begin()
+	if err != nil {
+		return 0, err
+	}
+	var copied int64
+	last, skip := int64(math.MinInt64), int64(1)
+	for {
+		if withRowid {
+			stmt.Reset()
+			stmt.BindInt64(1, last)
+		}
+		rows := 0
+		s := C.sqlite3_step(stmt.stmt)
+		for ; s == C.SQLITE_ROW; s = C.sqlite3_step(stmt.stmt) {
+			insStmt.Reset()
+			for i := 0; i < stmt.ColumnCount(); i++ {
+				insStmt.Bind(i+1, stmt.ColumnValue(i))
+			}
+			if err := insStmt.Step(); err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+			if withRowid {
+				last = stmt.ColumnInt64(0)
+			}
+			copied++
+			rows++
+		}
+		if s == C.SQLITE_DONE || !withRowid {
+			break
+		}
+		// Skip past the damaged range, twice as far each time no row could be read.
+		if rows > 0 {
+			skip = 1
+		} else {
+			skip *= 2
+		}
+		if last > math.MaxInt64-skip {
+			break
+		}
+		last += skip
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return copied, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestRecoverDatabase(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/damaged.db"
+	db, err := NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExecute(t, db, "PRAGMA page_size = 4096; CREATE TABLE items (id INTEGER PRIMARY KEY, data BLOB); CREATE INDEX items_data ON items (data)")
+	mustExecute(t, db, "WITH RECURSIVE s(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM s WHERE n < 2000) INSERT INTO items SELECT n, randomblob(100) FROM s")
+	root, err := db.queryInt64("SELECT rootpage FROM sqlite_master WHERE name = 'items'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A leaf page in the middle of the table.
+	page, err := db.queryInt64("SELECT pageno FROM dbstat WHERE name = 'items' AND pagetype = 'leaf' ORDER BY pageno LIMIT 1 OFFSET 20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteAt(bytes.Repeat([]byte{0xFF}, 4096), (page-1)*4096)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page == root {
+		t.Fatal("the damaged page is the table's root")
+	}
+	damaged, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := RecoverDatabase(path, dir+"/recovered.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(after, damaged) {
+		t.Error("recovering modified the damaged database")
+	}
+	if n == 0 || n >= 2000 {
+		t.Fatalf("recovered %d rows, want some but not all of 2000", n)
+	}
+	out, err := NewDatabase(dir + "/recovered.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if rows, err := out.queryInt64("SELECT count(*) FROM items"); err != nil || rows != n {
+		t.Errorf("the recovered database has %d rows (%v), want %d", rows, err, n)
+	}
+	// Rows after the damaged range must be kept.
+	if last, err := out.queryInt64("SELECT max(id) FROM items"); err != nil || last != 2000 {
+		t.Errorf("got the last recovered id %d (%v), want 2000", last, err)
+	}
+	if problems, err := out.QuickCheckN(10); err != nil || len(problems) != 0 {
+		t.Errorf("the recovered database has problems %v (%v)", problems, err)
+	}
+}
+
+func TestRecoverDatabaseMissingSource(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := RecoverDatabase(dir+"/missing.db", dir+"/recovered.db"); err == nil {
+		t.Fatal("recovering a missing database succeeded")
+	}
+	for _, name := range []string{"missing.db", "recovered.db"} {
+		if _, err := os.Stat(dir + "/" + name); !os.IsNotExist(err) {
+			t.Errorf("%s was created (%v)", name, err)
+		}
+	}
+}
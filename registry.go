@@ -0,0 +1,40 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import "fmt"
+
+// A set of named prepared statements.
+type StatementRegistry struct {
+	stmts map[string]*Statement
+}
+
+// Prepares each named query once and returns them as a registry.
+// If any query fails to prepare, the ones already prepared are closed.
+func (db *Database) PrepareSet(queries map[string]string) (*StatementRegistry, error) {
+	r := &StatementRegistry{stmts: make(map[string]*Statement, len(queries))}
+	for name, sql := range queries {
+		stmt, err := db.NewStatement(sql)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("query %s: %v", name, err)
+		}
+		r.stmts[name] = stmt
+	}
+	return r, nil
+}
+
+// Returns the statement with the name (nil if there's none).
+func (r *StatementRegistry) Get(name string) *Statement {
+	return r.stmts[name]
+}
+
+// Closes all statements in the registry.
+func (r *StatementRegistry) Close() {
+	for name, stmt := range r.stmts {
+		stmt.Close()
+		delete(r.stmts, name)
+	}
+}
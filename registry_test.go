@@ -0,0 +1,43 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import "testing"
+
+func TestPrepareSet(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (name TEXT)")
+	r, err := db.PrepareSet(map[string]string{
+		"insert": "INSERT INTO items VALUES (?)",
+		"count":  "SELECT count(*) FROM items",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get("insert").ExecArgs("alpha"); err != nil {
+		t.Fatal(err)
+	}
+	count := r.Get("count")
+	var n int
+	if err := count.StepRows(func() { n = count.ColumnInt(0) }); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got count %d, want 1", n)
+	}
+	if r.Get("missing") != nil {
+		t.Error("got a statement for a missing name")
+	}
+	r.Close()
+	if err := db.AssertNoOpenStatements(); err != nil {
+		t.Error(err)
+	}
+	if _, err := db.PrepareSet(map[string]string{"count": "SELECT count(*) FROM items", "broken": "SELEC"}); err == nil {
+		t.Error("preparing a broken query succeeded")
+	}
+	if err := db.AssertNoOpenStatements(); err != nil {
+		t.Errorf("after a failed PrepareSet: %v", err)
+	}
+}
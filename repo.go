@@ -0,0 +1,166 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// A repository of structs stored as rows of a table with an integer id column.
+// Struct fields are mapped to columns as in BindStruct and ScanStruct.
+type Repo[T any] struct {
+	db    *Database
+	table string
+	cols  []structColumn
+	id    int // index of the id field in cols, or -1
+}
+
+// Returns a new repository for the table.
+func NewRepo[T any](db *Database, table string) *Repo[T] {
+	var zero T
+	r := &Repo[T]{db: db, table: table, cols: structColumns(reflect.TypeOf(zero)), id: -1}
+	for i, col := range r.cols {
+		if strings.EqualFold(col.column, "id") {
+			r.id = i
+		}
+	}
+	return r
+}
+
+// Returns the id column's name.
+func (r *Repo[T]) idColumn() (string, error) {
+	if r.id < 0 {
+		return "", errors.New("the struct has no id field")
+	}
+	return QuoteIdentifier(r.cols[r.id].column), nil
+}
+
+// Inserts the record and returns its id. A zero id is assigned by the database.
+func (r *Repo[T]) Insert(ctx context.Context, rec *T) (int64, error) {
+	v := reflect.ValueOf(rec).Elem()
+	var names, params []string
+	for i, col := range r.cols {
+		if i == r.id && v.Field(col.index).IsZero() {
+			continue
+		}
+		names = append(names, QuoteIdentifier(col.column))
+		params = append(params, ":"+col.column)
+	}
+	sql := "INSERT INTO " + QuoteIdentifier(r.table) + " (" + strings.Join(names, ", ") + ") VALUES (" + strings.Join(params, ", ") + ")"
+	var id int64
+	err := r.db.withContext(ctx, sql, func() error {
+		stmt, err := r.db.NewStatement(sql)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		if err := stmt.BindStruct(rec); err != nil {
+			return err
+		}
+		if err := stmt.Step(); err != nil {
+			return err
+		}
+		id = r.db.LastInsertRowID()
+		return nil
+	})
+	return id, err
+}
+
+// Returns the record with the id, or ErrNoRows.
+func (r *Repo[T]) GetByID(ctx context.Context, id int64) (*T, error) {
+	idCol, err := r.idColumn()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.List(ctx, idCol+" = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrNoRows
+	}
+	return &rows[0], nil
+}
+
+// Updates the record with the id, or returns ErrNoRows if there's none.
+func (r *Repo[T]) Update(ctx context.Context, id int64, rec *T) error {
+	idCol, err := r.idColumn()
+	if err != nil {
+		return err
+	}
+	var sets []string
+	for i, col := range r.cols {
+		if i != r.id {
+			sets = append(sets, QuoteIdentifier(col.column)+" = :"+col.column)
+		}
+	}
+	sql := "UPDATE " + QuoteIdentifier(r.table) + " SET " + strings.Join(sets, ", ") + " WHERE " + idCol + " = ?"
+	return r.db.withContext(ctx, sql, func() error {
+		stmt, err := r.db.NewStatement(sql)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		if err := stmt.BindStruct(rec); err != nil {
+			return err
+		}
+		stmt.BindInt64(stmt.ParameterCount(), id)
+		if err := stmt.Step(); err != nil {
+			return err
+		}
+		if r.db.Changes() == 0 {
+			return ErrNoRows
+		}
+		return nil
+	})
+}
+
+// Deletes the record with the id, or returns ErrNoRows if there's none.
+func (r *Repo[T]) Delete(ctx context.Context, id int64) error {
+	idCol, err := r.idColumn()
+	if err != nil {
+		return err
+	}
+	sql := "DELETE FROM " + QuoteIdentifier(r.table) + " WHERE " + idCol + " = ?"
+	return r.db.withContext(ctx, sql, func() error {
+		stmt, err := r.db.NewStatement(sql)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		if err := stmt.ExecArgs(id); err != nil {
+			return err
+		}
+		if r.db.Changes() == 0 {
+			return ErrNoRows
+		}
+		return nil
+	})
+}
+
+// Returns the records matching the WHERE clause (all records if empty).
+func (r *Repo[T]) List(ctx context.Context, where string, args ...interface{}) ([]T, error) {
+	sql := "SELECT * FROM " + QuoteIdentifier(r.table)
+	if where != "" {
+		sql += " WHERE " + where
+	}
+	var rows []T
+	err := r.db.withContext(ctx, sql, func() error {
+		stmt, err := r.db.NewStatement(sql)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		if err := stmt.BindAll(args...); err != nil {
+			return err
+		}
+		rows, err = ScanAll[T](stmt)
+		return err
+	})
+	return rows, err
+}
@@ -0,0 +1,53 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRepo(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)")
+	repo := NewRepo[testItem](db, "items")
+	ctx := context.Background()
+	id, err := repo.Insert(ctx, &testItem{Name: "alpha"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Insert(ctx, &testItem{ID: 10, Name: "beta"}); err != nil {
+		t.Fatal(err)
+	}
+	item, err := repo.GetByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *item != (testItem{id, "alpha"}) {
+		t.Errorf("got %v, want {%d alpha}", *item, id)
+	}
+	if err := repo.Update(ctx, id, &testItem{Name: "gamma"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Update(ctx, 99, &testItem{Name: "delta"}); err != ErrNoRows {
+		t.Errorf("got %v updating a missing record, want ErrNoRows", err)
+	}
+	items, err := repo.List(ctx, "name > ? ORDER BY id", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0] != (testItem{id, "gamma"}) || items[1] != (testItem{10, "beta"}) {
+		t.Errorf("got %v, want [{%d gamma} {10 beta}]", items, id)
+	}
+	if err := repo.Delete(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, 10); err != ErrNoRows {
+		t.Errorf("got %v deleting a deleted record, want ErrNoRows", err)
+	}
+	if _, err := repo.GetByID(ctx, 10); err != ErrNoRows {
+		t.Errorf("got %v getting a deleted record, want ErrNoRows", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The result rows of a query.
+type Rows struct {
+	stmt *Statement
+	err  error
+	done bool
+	// Whether closing the rows finalizes the statement (otherwise it's only reset).
+	owned bool
+}
+
+// Runs the query and returns its rows, which must be closed.
+func (db *Database) Query(sql string, args ...interface{}) (*Rows, error) {
+	stmt, err := db.NewStatement(sql)
+	if err != nil {
+		return nil, err
+	}
+	if err := stmt.BindAll(args...); err != nil {
+		stmt.Close()
+		return nil, err
+	}
+	return &Rows{stmt: stmt, owned: true}, nil
+}
+
+// Resets the statement, clears its bindings, binds the arguments and returns its rows.
+// Closing the rows resets the statement instead of closing it so that it can be rerun.
+func (stmt *Statement) Rerun(args ...interface{}) (*Rows, error) {
+	stmt.Reset()
+	stmt.ClearBindings()
+	if err := stmt.BindAll(args...); err != nil {
+		return nil, err
+	}
+	return &Rows{stmt: stmt}, nil
+}
+
+// Runs the query with LIMIT and OFFSET bound as parameters and returns the window of rows.
+// The query is wrapped in a subquery, so it may have its own ORDER BY and LIMIT clauses.
+func (db *Database) QueryPage(sql string, limit, offset int, args ...interface{}) (*Rows, error) {
+	sql = strings.TrimRight(strings.TrimSpace(sql), ";")
+	return db.Query("SELECT * FROM ("+sql+") LIMIT ? OFFSET ?", append(args, limit, offset)...)
+}
+
+// Moves on to the next row and returns false if there are no more rows or an error occurred (see Err).
+func (rows *Rows) Next() bool {
+	if rows.done {
+		return false
+	}
+	switch C.sqlite3_step(rows.stmt.stmt) {
+	case C.SQLITE_ROW:
+		return true
+	case C.SQLITE_DONE:
+	default:
+		rows.err = errors.New(C.GoString(C.sqlite3_errmsg(rows.stmt.db.db)))
+	}
+	rows.done = true
+	return false
+}
+
+// Reads the columns of the current row into the destinations (see Statement.ColumnScan).
+func (rows *Rows) Scan(dest ...interface{}) error {
+	if len(dest) != rows.stmt.ColumnCount() {
+		return fmt.Errorf("expected %d destinations, got %d", rows.stmt.ColumnCount(), len(dest))
+	}
+	for i, d := range dest {
+		if err := rows.stmt.ColumnScan(i, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the column names.
+func (rows *Rows) Columns() []string {
+	cols := make([]string, rows.stmt.ColumnCount())
+	for i := range cols {
+		cols[i] = rows.stmt.ColumnName(i)
+	}
+	return cols
+}
+
+// Returns the underlying statement, e.g. for reading columns directly.
+func (rows *Rows) Statement() *Statement {
+	return rows.stmt
+}
+
+// Returns the error that stopped the iteration, if any.
+func (rows *Rows) Err() error {
+	return rows.err
+}
+
+// Closes the rows.
+func (rows *Rows) Close() {
+	rows.done = true
+	if rows.owned {
+		rows.stmt.Close()
+	} else {
+		rows.stmt.Reset()
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Returns the first column of all the rows as int64s.
+func collectInt64s(t *testing.T, rows *Rows) []int64 {
+	t.Helper()
+	defer rows.Close()
+	var vals []int64
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		vals = append(vals, v)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return vals
+}
+
+func TestQueryPage(t *testing.T) {
+	db := newTestDatabase(t)
+	createNums(t, db, 10)
+	// Neither a column named like the keyword nor the query's own LIMIT get in the way.
+	const sql = `SELECT n AS "limit" FROM nums WHERE n > ? ORDER BY n DESC LIMIT 8;`
+	var pages [][]int64
+	for offset := 0; offset < 8; offset += 3 {
+		rows, err := db.QueryPage(sql, 3, offset, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, collectInt64s(t, rows))
+	}
+	want := [][]int64{{10, 9, 8}, {7, 6, 5}, {4, 3}}
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("got pages %v, want %v", pages, want)
+	}
+}
+
+func TestRerun(t *testing.T) {
+	db := newTestDatabase(t)
+	createNums(t, db, 10)
+	stmt, err := db.NewStatement("SELECT n FROM nums WHERE n BETWEEN ? AND ? ORDER BY n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	for _, c := range []struct {
+		lo, hi int
+		want   []int64
+	}{{2, 4, []int64{2, 3, 4}}, {9, 20, []int64{9, 10}}, {3, 3, []int64{3}}} {
+		rows, err := stmt.Rerun(c.lo, c.hi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := collectInt64s(t, rows); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Rerun(%d, %d): got %v, want %v", c.lo, c.hi, got, c.want)
+		}
+	}
+}
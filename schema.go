@@ -0,0 +1,361 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A schema object from sqlite_master.
+type schemaObject struct {
+	typ, name, sql string
+}
+
+// Returns the user-defined schema objects ordered so that they can be created in an empty database
+// (tables, views, indexes, triggers). Shadow tables of virtual tables are left out because creating
+// the virtual table creates them.
+func (db *Database) schemaObjects() ([]schemaObject, error) {
+	stmt, err := db.NewStatement(`SELECT type, name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite\_%' ESCAPE '\'
+		AND tbl_name NOT IN (SELECT name FROM pragma_table_list WHERE schema = 'main' AND type = 'shadow')
+		ORDER BY CASE type WHEN 'table' THEN 0 WHEN 'view' THEN 1 WHEN 'index' THEN 2 ELSE 3 END, rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	var objs []schemaObject
+	if err := stmt.StepRows(func() {
+		objs = append(objs, schemaObject{stmt.ColumnText(0), stmt.ColumnText(1), stmt.ColumnText(2)})
+	}); err != nil {
+		return nil, err
+	}
+	return objs, nil
+}
+
+// Returns the CREATE statements of all tables, views, indexes and triggers (like .schema in the shell).
+// Objects are ordered so that the script can be executed in an empty database.
+func (db *Database) SchemaSQL() (string, error) {
+	objs, err := db.schemaObjects()
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, obj := range objs {
+		sb.WriteString(obj.sql)
+		sb.WriteString(";\n")
+	}
+	return sb.String(), nil
+}
+
+// Returns true if a table or view with the name exists.
+func (db *Database) tableExists(name string) (bool, error) {
+	n, err := db.queryInt64("SELECT count(*) FROM sqlite_master WHERE type IN ('table', 'view') AND name = ? COLLATE NOCASE", name)
+	return n > 0, err
+}
+
+// Renames the table, updating references to it in views and triggers.
+func (db *Database) RenameTable(oldName, newName string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	exists, err := db.tableExists(newName)
+	if err == nil && exists {
+		err = fmt.Errorf("table %s already exists", newName)
+	}
+	if err == nil {
+		err = db.Execute("ALTER TABLE " + QuoteIdentifier(oldName) + " RENAME TO " + QuoteIdentifier(newName))
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Column information as returned by PRAGMA table_info.
+type ColumnInfo struct {
+	CID        int
+	Name       string
+	Type       string
+	NotNull    bool
+	Default    interface{}
+	PrimaryKey int
+}
+
+// Returns the columns of the table.
+func (db *Database) TableInfo(table string) ([]ColumnInfo, error) {
+	stmt, err := db.NewStatement("PRAGMA table_info(" + QuoteIdentifier(table) + ")")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	var cols []ColumnInfo
+	if err := stmt.StepRows(func() {
+		cols = append(cols, ColumnInfo{
+			CID:        stmt.ColumnInt(0),
+			Name:       stmt.ColumnText(1),
+			Type:       stmt.ColumnText(2),
+			NotNull:    stmt.ColumnInt(3) != 0,
+			Default:    stmt.ColumnValue(4),
+			PrimaryKey: stmt.ColumnInt(5),
+		})
+	}); err != nil {
+		return nil, err
+	}
+	return cols, nil
+}
+
+// Adds a column with the definition (type, constraints, default) to the table.
+func (db *Database) AddColumn(table, column, columnDef string) error {
+	cols, err := db.TableInfo(table)
+	if err != nil {
+		return err
+	}
+	if len(cols) == 0 {
+		return fmt.Errorf("no such table: %s", table)
+	}
+	for _, col := range cols {
+		if strings.EqualFold(col.Name, column) {
+			return fmt.Errorf("column %s already exists in table %s", column, table)
+		}
+	}
+	return db.Execute("ALTER TABLE " + QuoteIdentifier(table) + " ADD COLUMN " + QuoteIdentifier(column) + " " + columnDef)
+}
+
+// Adds the column like AddColumn unless the table already has it and returns true if it was added.
+func (db *Database) AddColumnIfNotExists(table, column, columnDef string) (bool, error) {
+	cols, err := db.TableInfo(table)
+	if err != nil {
+		return false, err
+	}
+	for _, col := range cols {
+		if strings.EqualFold(col.Name, column) {
+			return false, nil
+		}
+	}
+	if err := db.AddColumn(table, column, columnDef); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Runs the CREATE statements in one transaction, ignoring objects that already exist.
+// Any other error rolls the whole transaction back.
+func (db *Database) EnsureSchema(statements []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, sql := range statements {
+		if err := db.Execute(sql); err != nil && !strings.Contains(err.Error(), "already exists") {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Attaches the database file under the schema name.
+func (db *Database) Attach(path, schema string) error {
+	stmt, err := db.NewStatement("ATTACH ? AS " + QuoteIdentifier(schema))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	return stmt.ExecArgs(path)
+}
+
+// Detaches the database attached under the schema name.
+func (db *Database) Detach(schema string) error {
+	return db.Execute("DETACH " + QuoteIdentifier(schema))
+}
+
+// Creates a new database at path with the options (e.g. a different page size or journal mode)
+// and copies the schema, the data and the user version into it.
+// If the export fails, a database file it created is removed.
+func (db *Database) ExportTo(path string, opts *Options) error {
+	objs, err := db.schemaObjects()
+	if err != nil {
+		return err
+	}
+	version, err := db.queryInt64("PRAGMA user_version")
+	if err != nil {
+		return err
+	}
+	hasSequence, err := db.tableExists("sqlite_sequence")
+	if err != nil {
+		return err
+	}
+	_, statErr := os.Stat(path)
+	created := os.IsNotExist(statErr)
+	dst, err := Open(path, opts)
+	if err == nil {
+		err = db.exportInto(dst, path, objs, version, hasSequence)
+		dst.Close()
+	}
+	if err != nil && created {
+		for _, suffix := range []string{"", "-journal", "-wal", "-shm"} {
+			os.Remove(path + suffix)
+		}
+	}
+	return err
+}
+
+// Creates the schema objects in dst (opened at path) and copies the data into it.
+func (db *Database) exportInto(dst *Database, path string, objs []schemaObject, version int64, hasSequence bool) error {
+	// Only tables are created before copying the data so that indexes are built once and triggers don't fire.
+	if err := dst.Execute(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		if obj.typ == "table" {
+			if err := dst.Execute(obj.sql); err != nil {
+				return err
+			}
+		}
+	}
+	if err := db.Attach(path, "export"); err != nil {
+		return err
+	}
+	defer db.Detach("export")
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		if obj.typ == "table" {
+			err = db.Execute("INSERT INTO export." + QuoteIdentifier(obj.name) + " SELECT * FROM main." + QuoteIdentifier(obj.name))
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	if hasSequence {
+		if err := db.Execute("INSERT INTO export.sqlite_sequence SELECT * FROM main.sqlite_sequence"); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, obj := range objs {
+		if obj.typ != "table" {
+			if err := dst.Execute(obj.sql); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Copies all rows of a table in another database file into a table of this one and returns the number of rows copied.
+// Both tables must have the same number of columns.
+func (db *Database) ImportTable(srcPath, srcTable, destTable string) (int64, error) {
+	if err := db.Attach(srcPath, "import_src"); err != nil {
+		return 0, err
+	}
+	defer db.Detach("import_src")
+	srcCols, err := db.queryInt64("SELECT count(*) FROM pragma_table_info(?, 'import_src')", srcTable)
+	if err != nil {
+		return 0, err
+	}
+	destCols, err := db.queryInt64("SELECT count(*) FROM pragma_table_info(?, 'main')", destTable)
+	if err != nil {
+		return 0, err
+	}
+	if srcCols == 0 {
+		return 0, fmt.Errorf("no such table in %s: %s", srcPath, srcTable)
+	}
+	if srcCols != destCols {
+		return 0, fmt.Errorf("schema mismatch: %s has %d columns, %s has %d", srcTable, srcCols, destTable, destCols)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	if err := db.Execute("INSERT INTO main." + QuoteIdentifier(destTable) + " SELECT * FROM import_src." + QuoteIdentifier(srcTable)); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	n := db.Changes()
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return n, nil
+}
+
+// The kind of a schema difference.
+type SchemaDiffKind int
+
+const (
+	// An expected table doesn't exist.
+	TableMissing SchemaDiffKind = iota
+	// A table's definition differs from the expected one.
+	TableChanged
+	// A table exists that isn't expected.
+	TableExtra
+)
+
+// A difference between the expected and the actual schema.
+type SchemaDiff struct {
+	Table    string
+	Kind     SchemaDiffKind
+	Expected string
+	Actual   string
+}
+
+// Matches spaces around punctuation in SQL.
+var punctuationSpace = regexp.MustCompile(` ?([(),]) ?`)
+
+// Returns the CREATE statement normalized for comparison (case, whitespace, quotes).
+func normalizeSQL(sql string) string {
+	sql = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(sql), ";"))
+	sql = strings.NewReplacer(`"`, "", "`", "", " if not exists", "").Replace(sql)
+	sql = strings.Join(strings.Fields(sql), " ")
+	return punctuationSpace.ReplaceAllString(sql, "$1")
+}
+
+// Compares the tables of the database against the expected CREATE statements keyed by table name.
+// Definitions are compared after normalizing case, whitespace and identifier quotes.
+// Shadow tables of virtual tables aren't compared.
+func (db *Database) SchemaMatches(expected map[string]string) ([]SchemaDiff, error) {
+	objs, err := db.schemaObjects()
+	if err != nil {
+		return nil, err
+	}
+	actual := make(map[string]string)
+	for _, obj := range objs {
+		if obj.typ == "table" {
+			actual[strings.ToLower(obj.name)] = obj.sql
+		}
+	}
+	var diffs []SchemaDiff
+	seen := make(map[string]bool)
+	for table, sql := range expected {
+		key := strings.ToLower(table)
+		seen[key] = true
+		if a, ok := actual[key]; !ok {
+			diffs = append(diffs, SchemaDiff{Table: table, Kind: TableMissing, Expected: sql})
+		} else if normalizeSQL(a) != normalizeSQL(sql) {
+			diffs = append(diffs, SchemaDiff{Table: table, Kind: TableChanged, Expected: sql, Actual: a})
+		}
+	}
+	for _, obj := range objs {
+		if obj.typ == "table" && !seen[strings.ToLower(obj.name)] {
+			diffs = append(diffs, SchemaDiff{Table: obj.name, Kind: TableExtra, Actual: obj.sql})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Table < diffs[j].Table })
+	return diffs, nil
+}
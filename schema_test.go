@@ -0,0 +1,215 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// The schema of the test database, including a virtual table with shadow tables.
+const testSchema = `CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT NOT NULL);
+CREATE VIRTUAL TABLE docs USING fts5(body);
+CREATE VIEW names AS SELECT name FROM items;
+CREATE INDEX items_name ON items (name);
+CREATE TRIGGER items_doc AFTER INSERT ON items BEGIN INSERT INTO docs (body) VALUES (new.name); END;`
+
+func TestSchemaSQLRoundTrip(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, testSchema)
+	sql, err := db.SchemaSQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := newTestDatabase(t)
+	mustExecute(t, replay, sql)
+	replayed, err := replay.SchemaSQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != sql {
+		t.Errorf("replayed schema differs:\n%s\nwant:\n%s", replayed, sql)
+	}
+}
+
+func TestExportToVirtualTable(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, testSchema)
+	mustExecute(t, db, "INSERT INTO items (name) VALUES ('alpha'), ('beta')")
+	path := t.TempDir() + "/export.db"
+	if err := db.ExportTo(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	dst, err := NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	n, err := dst.queryInt64("SELECT count(*) FROM docs WHERE docs MATCH 'beta'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("got %d matching documents, want 1", n)
+	}
+}
+
+func TestExportToRemovesFileOnError(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, testSchema)
+	path := t.TempDir() + "/export.db"
+	opts := DefaultOptions()
+	opts.PragmaHook = func(dst *Database) error {
+		return dst.Execute("CREATE TABLE items (x)")
+	}
+	if err := db.ExportTo(path, opts); err == nil {
+		t.Fatal("export into a database with a conflicting table succeeded")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("partial export wasn't removed: %v", err)
+	}
+}
+
+func TestSchemaMatchesIgnoresShadowTables(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE VIRTUAL TABLE docs USING fts5(body)")
+	diffs, err := db.SchemaMatches(map[string]string{"docs": "CREATE VIRTUAL TABLE docs USING fts5(body)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("got diffs %v, want none", diffs)
+	}
+	diffs, err = db.SchemaMatches(map[string]string{"items": "CREATE TABLE items (id INTEGER PRIMARY KEY)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 || diffs[0].Table != "docs" || diffs[0].Kind != TableExtra || diffs[1].Kind != TableMissing {
+		t.Errorf("got diffs %v, want docs extra and items missing", diffs)
+	}
+}
+
+func TestSchemaSQLTableNamedLikeInternal(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE sqlitemeta (x); CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT)")
+	sql, err := db.SchemaSQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sql, "CREATE TABLE sqlitemeta") {
+		t.Errorf("the schema lacks the table sqlitemeta:\n%s", sql)
+	}
+	// The internal sqlite_sequence table must still be left out.
+	if strings.Contains(sql, "sqlite_sequence") {
+		t.Errorf("the schema includes sqlite_sequence:\n%s", sql)
+	}
+}
+
+func TestRenameTable(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, `CREATE TABLE items (name TEXT); INSERT INTO items VALUES ('alpha'), ('beta');
+		CREATE VIEW names AS SELECT name FROM items; CREATE TABLE taken (x)`)
+	if err := db.RenameTable("items", "taken"); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("got %v renaming onto an existing table, want an already exists error", err)
+	}
+	if err := db.RenameTable("items", "products"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := db.queryInt64("SELECT count(*) FROM products"); err != nil || n != 2 {
+		t.Errorf("got %d rows (%v) in the renamed table, want 2", n, err)
+	}
+	if n, err := db.queryInt64("SELECT count(*) FROM names"); err != nil || n != 2 {
+		t.Errorf("got %d rows (%v) from the view, want 2", n, err)
+	}
+	if n, _ := db.queryInt64("SELECT count(*) FROM sqlite_master WHERE name = 'names' AND sql LIKE '%products%'"); n != 1 {
+		t.Error("the view doesn't reference the renamed table")
+	}
+}
+
+func TestAddColumn(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (name TEXT); INSERT INTO items VALUES ('alpha'), ('beta')")
+	if err := db.AddColumn("items", "qty", "INTEGER NOT NULL DEFAULT 5"); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := db.queryInt64("SELECT count(*) FROM items WHERE qty = 5"); n != 2 {
+		t.Errorf("got %d existing rows with the default, want 2", n)
+	}
+	if err := db.AddColumn("items", "QTY", "INTEGER"); err == nil {
+		t.Error("adding an existing column succeeded")
+	}
+}
+
+func TestEnsureSchema(t *testing.T) {
+	db := newTestDatabase(t)
+	schema := []string{
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE INDEX items_name ON items (name)",
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.EnsureSchema(schema); err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+	broken := []string{"CREATE TABLE extra (x)", "CREATE TABLE oops (x"}
+	if err := db.EnsureSchema(broken); err == nil {
+		t.Fatal("a broken statement didn't fail")
+	}
+	if exists, _ := db.tableExists("extra"); exists {
+		t.Error("the table created before the broken statement wasn't rolled back")
+	}
+}
+
+func TestImportTable(t *testing.T) {
+	dir := t.TempDir()
+	src, err := NewDatabase(dir + "/src.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExecute(t, src, `CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 500)
+		INSERT INTO items SELECT i, 'item ' || i FROM n`)
+	src.Close()
+	db, err := NewDatabase(dir + "/dest.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mustExecute(t, db, "CREATE TABLE copied (id INTEGER PRIMARY KEY, name TEXT); CREATE TABLE narrow (id INTEGER)")
+	n, err := db.ImportTable(dir+"/src.db", "items", "copied")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 500 {
+		t.Errorf("got %d rows copied, want 500", n)
+	}
+	if n, _ := db.queryInt64("SELECT count(*) FROM copied WHERE name = 'item ' || id"); n != 500 {
+		t.Errorf("got %d matching rows in the copy, want 500", n)
+	}
+	if _, err := db.ImportTable(dir+"/src.db", "items", "narrow"); err == nil || !strings.Contains(err.Error(), "schema mismatch") {
+		t.Errorf("got %v, want a schema mismatch error", err)
+	}
+	if _, err := db.queryInt64("SELECT count(*) FROM import_src.items"); err == nil {
+		t.Error("the source database is still attached")
+	}
+}
+
+func TestAddColumnIfNotExists(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (name TEXT)")
+	for i, want := range []bool{true, false} {
+		added, err := db.AddColumnIfNotExists("items", "qty", "INTEGER DEFAULT 0")
+		if err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+		if added != want {
+			t.Errorf("call %d: got added %v, want %v", i+1, added, want)
+		}
+	}
+	if cols, _ := db.TableInfo("items"); len(cols) != 2 {
+		t.Errorf("got %d columns, want 2", len(cols))
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <stdlib.h>
+#include <string.h>
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"unsafe"
+)
+
+// Returns an in-memory database backed by a copy of the serialized database.
+func deserialize(data []byte, flags C.uint) (*Database, error) {
+	db, err := NewDatabase(":memory:")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite keeps the buffer for the lifetime of the connection so it must be owned by SQLite.
+	buf := C.sqlite3_malloc64(C.sqlite3_uint64(len(data)))
+	if buf == nil && len(data) > 0 {
+		db.Close()
+		return nil, errors.New("out of memory")
+	}
+	if len(data) > 0 {
+		C.memcpy(buf, unsafe.Pointer(&data[0]), C.size_t(len(data)))
+	}
+	cmain := C.CString("main")
+	defer C.free(unsafe.Pointer(cmain))
+	n := C.sqlite3_int64(len(data))
+	s := C.sqlite3_deserialize(db.db, cmain, (*C.uchar)(buf), n, n, flags|C.SQLITE_DESERIALIZE_FREEONCLOSE)
+	if s != C.SQLITE_OK {
+		err := errors.New(C.GoString(C.sqlite3_errmsg(db.db)))
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Calls fn with the serialized main database. The bytes are only valid during the call.
+func (db *Database) serialize(fn func([]byte) error) error {
+	cmain := C.CString("main")
+	defer C.free(unsafe.Pointer(cmain))
+	var size C.sqlite3_int64
+	p := C.sqlite3_serialize(db.db, cmain, &size, 0)
+	if p == nil {
+		if size == 0 {
+			return fn(nil)
+		}
+		return errors.New("couldn't serialize database")
+	}
+	defer C.sqlite3_free(unsafe.Pointer(p))
+	return fn(unsafe.Slice((*byte)(unsafe.Pointer(p)), int(size)))
+}
+
+// Returns a read-only in-memory database backed by a copy of the serialized database.
+func OpenBytes(data []byte) (*Database, error) {
+	return deserialize(data, C.SQLITE_DESERIALIZE_READONLY)
+}
+
+// Returns a SHA-256 hash of the serialized main database.
+// The header's change counters and schema cookie are ignored, but free pages and the page layout are not,
+// so databases with the same logical content only hash equally after VACUUM.
+func (db *Database) ContentHash() ([]byte, error) {
+	h := sha256.New()
+	if err := db.serialize(func(data []byte) error {
+		if len(data) >= 100 {
+			var header [100]byte
+			copy(header[:], data)
+			// The file change counter, the schema cookie (bumped by VACUUM) and the version-valid-for number.
+			copy(header[24:28], []byte{0, 0, 0, 0})
+			copy(header[40:44], []byte{0, 0, 0, 0})
+			copy(header[92:96], []byte{0, 0, 0, 0})
+			h.Write(header[:])
+			data = data[100:]
+		}
+		h.Write(data)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// Writes the serialized main database to the writer, prefixed with its length (8 bytes big-endian).
+func (db *Database) DumpBinary(w io.Writer) error {
+	return db.serialize(func(data []byte) error {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(data)))
+		if _, err := w.Write(n[:]); err != nil {
+			return err
+		}
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// Returns a writable in-memory database read from a stream written by DumpBinary.
+func LoadBinary(r io.Reader) (*Database, error) {
+	var n [8]byte
+	if _, err := io.ReadFull(r, n[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint64(n[:])
+	if size > math.MaxInt64 {
+		return nil, fmt.Errorf("invalid database length %d", size)
+	}
+	// The buffer grows as data arrives instead of trusting the length, which may come from the network.
+	data, err := io.ReadAll(io.LimitReader(r, int64(size)))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) < size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return deserialize(data, C.SQLITE_DESERIALIZE_RESIZEABLE)
+}
@@ -0,0 +1,96 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestDumpLoadBinary(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (name TEXT); INSERT INTO items VALUES ('alpha'), ('beta')")
+	var buf bytes.Buffer
+	if err := db.DumpBinary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBinary(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	if n, err := loaded.queryInt64("SELECT count(*) FROM items"); err != nil || n != 2 {
+		t.Errorf("got %d rows (%v), want 2", n, err)
+	}
+}
+
+func TestLoadBinaryHugeLength(t *testing.T) {
+	for _, size := range []uint64{1 << 62, 1 << 63, 1 << 40} {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], size)
+		r := io.MultiReader(bytes.NewReader(n[:]), bytes.NewReader(make([]byte, 100)))
+		if db, err := LoadBinary(r); err == nil {
+			db.Close()
+			t.Errorf("length %d: loading a truncated stream succeeded", size)
+		}
+	}
+}
+
+func TestOpenBytes(t *testing.T) {
+	path := t.TempDir() + "/asset.db"
+	src, err := NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExecute(t, src, "CREATE TABLE items (name TEXT); INSERT INTO items VALUES ('alpha'), ('beta')")
+	src.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if n, err := db.queryInt64("SELECT count(*) FROM items"); err != nil || n != 2 {
+		t.Errorf("got %d rows (%v), want 2", n, err)
+	}
+	if err := db.Execute("INSERT INTO items VALUES ('gamma')"); err == nil {
+		t.Error("writing to a read-only database succeeded")
+	}
+}
+
+func TestContentHashAfterVacuum(t *testing.T) {
+	a := newTestDatabase(t)
+	mustExecute(t, a, "CREATE TABLE items (name TEXT); INSERT INTO items VALUES ('alpha'), ('beta')")
+	b := newTestDatabase(t)
+	// Reaches the same content through a different history, with extra schema changes and free pages.
+	mustExecute(t, b, `CREATE TABLE scratch (data BLOB); INSERT INTO scratch VALUES (randomblob(20000));
+		CREATE TABLE items (name TEXT); INSERT INTO items VALUES ('alpha'), ('beta'), ('gamma');
+		DELETE FROM items WHERE name = 'gamma'; DROP TABLE scratch`)
+	mustExecute(t, b, "DELETE FROM items; INSERT INTO items VALUES ('alpha'), ('beta')")
+	for _, db := range []*Database{a, b} {
+		mustExecute(t, db, "VACUUM")
+	}
+	ha, err := a.ContentHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb, err := b.ContentHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ha, hb) {
+		t.Error("databases with the same content hash differently after VACUUM")
+	}
+	mustExecute(t, b, "INSERT INTO items VALUES ('gamma'); VACUUM")
+	if hc, _ := b.ContentHash(); bytes.Equal(ha, hc) {
+		t.Error("databases with different content hash equally")
+	}
+}
@@ -6,10 +6,20 @@
 package sqlite
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf16"
 	"unsafe"
 )
 
@@ -19,29 +29,257 @@ import (
 inline sqlite3_destructor_type sqlite3_const_transient() { return SQLITE_TRANSIENT; }
 inline sqlite3_destructor_type sqlite3_const_static() { return SQLITE_STATIC; }
 inline char* sqlite3_charptr(unsigned char* s) { return (void*)s; }
+inline int sqlite3_db_config_int(sqlite3* db, int op, int val) { return sqlite3_db_config(db, op, val, (int*)0); }
 #cgo LDFLAGS: -lsqlite3
 */
 import "C"
 
 // A database instance.
 type Database struct {
-	db   *C.sqlite3
-	lock sync.Mutex
+	db      *C.sqlite3
+	lock    sync.Mutex
+	opts    Options
+	busy    unsafe.Pointer
+	slowLog unsafe.Pointer
+	guard   uintptr
+	busyCtx uintptr
+	queries queryRegistry
+	stmts   statementSet
+	live    uint64
+}
+
+// The open statements of a connection.
+type statementSet struct {
+	sync.Mutex
+	values map[*Statement]struct{}
+}
+
+// Database options.
+type Options struct {
+	// Whether Close skips the TRUNCATE checkpoint that leaves no -wal file behind.
+	// Skipping it makes closing cheaper but the WAL file keeps its size until the next checkpoint.
+	NoCheckpointOnClose bool
+	// The page size of a newly created database (0 for the default).
+	PageSize int
+	// The journal mode ("" for the default).
+	JournalMode JournalMode
+	// The name of the VFS, e.g. one registered with RegisterVFS ("" for the default).
+	VFS string
+	// Whether the database is opened read-only, in which case the file must already exist.
+	ReadOnly bool
+	// Called after the other options have been applied to configure each new connection,
+	// e.g. the connections of a Pool (nil for none).
+	PragmaHook func(*Database) error
+}
+
+// A journal mode.
+type JournalMode string
+
+const (
+	JournalDelete   JournalMode = "DELETE"
+	JournalTruncate JournalMode = "TRUNCATE"
+	JournalPersist  JournalMode = "PERSIST"
+	JournalMemory   JournalMode = "MEMORY"
+	JournalWAL      JournalMode = "WAL"
+	JournalOff      JournalMode = "OFF"
+)
+
+// Returns true if SQLite was compiled with the option (without the SQLITE_ prefix).
+func CompileOptionUsed(name string) bool {
+	cs := C.CString(name)
+	defer C.free(unsafe.Pointer(cs))
+	return C.sqlite3_compileoption_used(cs) != 0
+}
+
+// The number of currently open connections.
+var openConnections int64
+
+// Returns the number of currently open connections.
+func OpenConnectionCount() int {
+	return int(atomic.LoadInt64(&openConnections))
+}
+
+// The names of open connections keyed by an id so that they don't keep the connections alive.
+var liveDatabases struct {
+	sync.Mutex
+	names          map[uint64]string
+	next, memories uint64
+}
+
+// Registers the open connection under its filename or "memory#N" for in-memory and temporary databases.
+func (db *Database) registerLive() {
+	name := db.Filename()
+	liveDatabases.Lock()
+	defer liveDatabases.Unlock()
+	if liveDatabases.names == nil {
+		liveDatabases.names = make(map[uint64]string)
+	}
+	if name == "" {
+		liveDatabases.memories++
+		name = fmt.Sprintf("memory#%d", liveDatabases.memories)
+	}
+	liveDatabases.next++
+	db.live = liveDatabases.next
+	liveDatabases.names[db.live] = name
+}
+
+// Removes the connection from the registry of open connections.
+func (db *Database) unregisterLive() {
+	liveDatabases.Lock()
+	delete(liveDatabases.names, db.live)
+	liveDatabases.Unlock()
+}
+
+// Returns the filenames (or "memory#N" for in-memory and temporary databases) of all open connections
+// in the order they were opened, e.g. to find connections leaked by tests.
+func LiveDatabases() []string {
+	liveDatabases.Lock()
+	defer liveDatabases.Unlock()
+	ids := make([]uint64, 0, len(liveDatabases.names))
+	for id := range liveDatabases.names {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = liveDatabases.names[id]
+	}
+	return names
+}
+
+// Returns the default options.
+func DefaultOptions() *Options {
+	return &Options{}
 }
 
 // Returns a new database.
 func NewDatabase(path string) (*Database, error) {
+	return Open(path, nil)
+}
+
+// Returns a new private temporary on-disk database, which is deleted automatically on Close.
+func NewTempDatabase() (*Database, error) {
+	return Open("", nil)
+}
+
+// Returns a new database opened with the provided options (the defaults if nil).
+func Open(path string, opts *Options) (*Database, error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
 	var db *C.sqlite3
-	p := C.CString(path)
-	defer C.free(unsafe.Pointer(p))
-	s := C.sqlite3_open(p, &db)
+	s := openHandle(path, opts, &db)
 	if s == C.SQLITE_OK {
-		return &Database{db: db}, nil
+		if opts.NoCheckpointOnClose {
+			C.sqlite3_db_config_int(db, C.SQLITE_DBCONFIG_NO_CKPT_ON_CLOSE, 1)
+		}
+		atomic.AddInt64(&openConnections, 1)
+		d := &Database{db: db, opts: *opts}
+		d.registerLive()
+		if err := d.applyOptions(); err != nil {
+			d.Close()
+			return nil, err
+		}
+		return d, nil
 	} else {
+		// A handle is allocated even if opening fails.
+		C.sqlite3_close(db)
 		return nil, fmt.Errorf("couldn't open database file (%s)", path)
 	}
 }
 
+// Opens the SQLite connection for the path using the VFS and access mode in the options.
+func openHandle(path string, opts *Options, db **C.sqlite3) C.int {
+	p := C.CString(path)
+	defer C.free(unsafe.Pointer(p))
+	var vfs *C.char
+	if opts.VFS != "" {
+		vfs = C.CString(opts.VFS)
+		defer C.free(unsafe.Pointer(vfs))
+	}
+	flags := C.int(C.SQLITE_OPEN_READWRITE | C.SQLITE_OPEN_CREATE)
+	if opts.ReadOnly {
+		flags = C.SQLITE_OPEN_READONLY
+	}
+	return C.sqlite3_open_v2(p, db, flags, vfs)
+}
+
+// Applies the pragmas corresponding to the options.
+func (db *Database) applyOptions() error {
+	if db.opts.PageSize > 0 {
+		if err := db.Execute(fmt.Sprintf("PRAGMA page_size = %d", db.opts.PageSize)); err != nil {
+			return err
+		}
+	}
+	if db.opts.JournalMode != "" {
+		if err := db.Execute("PRAGMA journal_mode = " + string(db.opts.JournalMode)); err != nil {
+			return err
+		}
+	}
+	if db.opts.PragmaHook != nil {
+		return db.opts.PragmaHook(db)
+	}
+	return nil
+}
+
+// Returns the file name of the main database (empty for in-memory and temporary databases).
+func (db *Database) Filename() string {
+	return C.GoString(db.uriFilename())
+}
+
+// Returns true if both connections have the same main database file.
+// Temporary and in-memory databases are always distinct.
+func SameDatabase(a, b *Database) (bool, error) {
+	pa, pb := a.Filename(), b.Filename()
+	if pa == "" || pb == "" {
+		return false, nil
+	}
+	var err error
+	if pa, err = filepath.EvalSymlinks(pa); err != nil {
+		return false, err
+	}
+	if pb, err = filepath.EvalSymlinks(pb); err != nil {
+		return false, err
+	}
+	return pa == pb, nil
+}
+
+// Returns the main database filename as understood by the sqlite3_uri_* functions.
+func (db *Database) uriFilename() *C.char {
+	cmain := C.CString("main")
+	defer C.free(unsafe.Pointer(cmain))
+	return C.sqlite3_db_filename(db.db, cmain)
+}
+
+// Returns the value of a query parameter of the URI the database was opened with.
+func (db *Database) URIParameter(name string) (string, bool) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	v := C.sqlite3_uri_parameter(db.uriFilename(), cname)
+	if v == nil {
+		return "", false
+	}
+	return C.GoString(v), true
+}
+
+// Returns the URI query parameter as a boolean, or def if it's missing.
+func (db *Database) URIBoolean(name string, def bool) bool {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	cdef := C.int(0)
+	if def {
+		cdef = 1
+	}
+	return C.sqlite3_uri_boolean(db.uriFilename(), cname, cdef) != 0
+}
+
+// Returns the URI query parameter as int64, or def if it's missing.
+func (db *Database) URIInt64(name string, def int64) int64 {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	return int64(C.sqlite3_uri_int64(db.uriFilename(), cname, C.sqlite3_int64(def)))
+}
+
 // Activates the associated lock.
 func (db *Database) Lock() {
 	db.lock.Lock()
@@ -52,12 +290,110 @@ func (db *Database) Unlock() {
 	db.lock.Unlock()
 }
 
+// Enters SQLite's connection mutex (recursive, so calls can nest).
+// The mutex only exists in SQLite's serialized threading mode, otherwise this is a no-op
+// and the caller is responsible for serializing access (e.g. with Lock).
+// The mutex is owned by an OS thread, so the goroutine is locked to its thread until the matching LeaveMutex.
+func (db *Database) EnterMutex() {
+	runtime.LockOSThread()
+	C.sqlite3_mutex_enter(C.sqlite3_db_mutex(db.db))
+}
+
+// Leaves SQLite's connection mutex. It must be called on the goroutine that called EnterMutex.
+func (db *Database) LeaveMutex() {
+	C.sqlite3_mutex_leave(C.sqlite3_db_mutex(db.db))
+	runtime.UnlockOSThread()
+}
+
 // Closes the database.
 func (db *Database) Close() {
+	if !db.opts.NoCheckpointOnClose {
+		C.sqlite3_wal_checkpoint_v2(db.db, nil, C.SQLITE_CHECKPOINT_TRUNCATE, nil, nil)
+	}
+	db.clearSlowLog()
+	db.SetCommitGuard(nil)
+	db.clearContextBusy()
 	C.sqlite3_close(db.db)
+	C.free(db.busy)
+	db.busy = nil
+	atomic.AddInt64(&openConnections, -1)
+	db.unregisterLive()
 	log.Print("database closed")
 }
 
+// Returns an error listing the SQL of all statements of the connection that haven't been closed,
+// e.g. to catch leaked statements during shutdown before calling Close.
+func (db *Database) AssertNoOpenStatements() error {
+	var open []string
+	for s := C.sqlite3_next_stmt(db.db, nil); s != nil; s = C.sqlite3_next_stmt(db.db, s) {
+		open = append(open, C.GoString(C.sqlite3_sql(s)))
+	}
+	if len(open) > 0 {
+		return fmt.Errorf("%d statements not closed: %s", len(open), strings.Join(open, "; "))
+	}
+	return nil
+}
+
+// Closes the underlying connection and opens the same file again with the same options.
+// It must be called in the child process after fork, before the connection is used,
+// because the connection inherited from the parent shares its file descriptors.
+// All statements of the connection are finalized, closing them afterwards is a no-op
+// and they mustn't be used otherwise. The busy handler, the commit guard and the slow query log are kept.
+func (db *Database) ReopenAfterFork() error {
+	path := db.Filename()
+	if path == "" {
+		return errors.New("temporary and in-memory databases can't be reopened")
+	}
+	db.stmts.Lock()
+	for stmt := range db.stmts.values {
+		stmt.stmt = nil
+	}
+	db.stmts.values = nil
+	db.stmts.Unlock()
+	for s := C.sqlite3_next_stmt(db.db, nil); s != nil; s = C.sqlite3_next_stmt(db.db, nil) {
+		C.sqlite3_finalize(s)
+	}
+	// The parent process owns the WAL, so the child doesn't checkpoint it.
+	C.sqlite3_db_config_int(db.db, C.SQLITE_DBCONFIG_NO_CKPT_ON_CLOSE, 1)
+	C.sqlite3_close(db.db)
+	if s := openHandle(path, &db.opts, &db.db); s != C.SQLITE_OK {
+		return fmt.Errorf("couldn't reopen database file (%s)", path)
+	}
+	if db.opts.NoCheckpointOnClose {
+		C.sqlite3_db_config_int(db.db, C.SQLITE_DBCONFIG_NO_CKPT_ON_CLOSE, 1)
+	}
+	db.restoreBusyHandler()
+	db.restoreCommitGuard()
+	db.restoreSlowLog()
+	return db.applyOptions()
+}
+
+// Returns the rowid of the most recent successful INSERT.
+func (db *Database) LastInsertRowID() int64 {
+	return int64(C.sqlite3_last_insert_rowid(db.db))
+}
+
+// Sets the value returned by LastInsertRowID, e.g. so that a virtual table emulating
+// an INSERT can report the rowid it assigned.
+func (db *Database) SetLastInsertRowID(id int64) {
+	C.sqlite3_set_last_insert_rowid(db.db, C.sqlite3_int64(id))
+}
+
+// Returns true if a transaction is open (i.e. the connection isn't in autocommit mode).
+func (db *Database) InTransaction() bool {
+	return C.sqlite3_get_autocommit(db.db) == 0
+}
+
+// Returns the number of rows changed by the most recent statement.
+func (db *Database) Changes() int64 {
+	return int64(C.sqlite3_changes64(db.db))
+}
+
+// Returns the number of rows changed since the database was opened.
+func (db *Database) TotalChanges() int64 {
+	return int64(C.sqlite3_total_changes64(db.db))
+}
+
 // Executes an SQL statement.
 func (db *Database) Execute(sql string) error {
 	cs := C.CString(sql)
@@ -70,10 +406,52 @@ func (db *Database) Execute(sql string) error {
 	return nil
 }
 
+// Executes an SQL statement, converting a panic into an error.
+// Only Go panics can be recovered; a crash inside SQLite itself still terminates the process.
+func (db *Database) SafeExecute(sql string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic while executing SQL: %v", r)
+		}
+	}()
+	return db.Execute(sql)
+}
+
+// Runs a query returning a single integer.
+func (db *Database) queryInt64(sql string, args ...interface{}) (int64, error) {
+	stmt, err := db.NewStatement(sql)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	if err := stmt.BindAll(args...); err != nil {
+		return 0, err
+	}
+	s := C.sqlite3_step(stmt.stmt)
+	if s != C.SQLITE_ROW {
+		if s == C.SQLITE_DONE {
+			return 0, errors.New("query returned no rows")
+		}
+		return 0, errors.New(C.GoString(C.sqlite3_errmsg(db.db)))
+	}
+	return stmt.ColumnInt64(0), nil
+}
+
+// Returns the identifier quoted for use in SQL.
+func QuoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
 // An SQL statement.
 type Statement struct {
-	stmt *C.sqlite3_stmt
-	db   *Database
+	stmt   *C.sqlite3_stmt
+	db     *Database
+	strict bool
+	bound  []bool
+	values []interface{}
+	// Column names cached by Header, valid for the recorded reprepare count.
+	header     []string
+	headerPrep int
 }
 
 // Returns a new statement.
@@ -81,20 +459,184 @@ func (db *Database) NewStatement(sql string) (*Statement, error) {
 	cs := C.CString(sql)
 	defer C.free(unsafe.Pointer(cs))
 	var stmt *C.sqlite3_stmt
-	s := C.sqlite3_prepare(db.db, cs, -1, &stmt, nil)
+	s := C.sqlite3_prepare_v2(db.db, cs, -1, &stmt, nil)
 	if s != C.SQLITE_OK {
 		return nil, errors.New(C.GoString(C.sqlite3_errmsg(db.db)))
 	}
-	return &Statement{stmt, db}, nil
+	st := &Statement{stmt: stmt, db: db}
+	db.stmts.Lock()
+	if db.stmts.values == nil {
+		db.stmts.values = make(map[*Statement]struct{})
+	}
+	db.stmts.values[st] = struct{}{}
+	db.stmts.Unlock()
+	return st, nil
+}
+
+// Prepares the statement's SQL on another connection.
+func (stmt *Statement) CloneOn(db *Database) (*Statement, error) {
+	return db.NewStatement(C.GoString(C.sqlite3_sql(stmt.stmt)))
+}
+
+// Executes the statements of the script in one transaction, binding the i-th argument set
+// to the i-th statement (statements without an argument set are run without arguments).
+func (db *Database) ExecuteScriptArgs(sql string, argsPerStatement [][]interface{}) error {
+	cs := C.CString(sql)
+	defer C.free(unsafe.Pointer(cs))
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	n := 0
+	for tail := cs; *tail != 0; {
+		var cstmt *C.sqlite3_stmt
+		if s := C.sqlite3_prepare_v2(db.db, tail, -1, &cstmt, &tail); s != C.SQLITE_OK {
+			err := fmt.Errorf("statement %d: %s", n+1, C.GoString(C.sqlite3_errmsg(db.db)))
+			tx.Rollback()
+			return err
+		}
+		if cstmt == nil {
+			// Only whitespace or comments remained.
+			continue
+		}
+		stmt := &Statement{stmt: cstmt, db: db}
+		var args []interface{}
+		if n < len(argsPerStatement) {
+			args = argsPerStatement[n]
+		}
+		err := stmt.BindAll(args...)
+		if err != nil {
+			err = fmt.Errorf("statement %d: %v", n+1, err)
+		} else if stmt.StepRows(func() {}) != nil {
+			err = fmt.Errorf("statement %d: %s", n+1, C.GoString(C.sqlite3_errmsg(db.db)))
+		}
+		stmt.Close()
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		n++
+	}
+	if n < len(argsPerStatement) {
+		tx.Rollback()
+		return fmt.Errorf("%d argument sets for %d statements", len(argsPerStatement), n)
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return nil
 }
 
 // Closes the statement.
 func (stmt *Statement) Close() {
-	C.sqlite3_finalize(stmt.stmt)
+	stmt.db.stmts.Lock()
+	delete(stmt.db.stmts.values, stmt)
+	stmt.db.stmts.Unlock()
+	if stmt.stmt != nil {
+		C.sqlite3_finalize(stmt.stmt)
+		stmt.stmt = nil
+	}
+}
+
+// Resets the statement so that it can be stepped again.
+func (stmt *Statement) Reset() {
+	C.sqlite3_reset(stmt.stmt)
+}
+
+// Sets all parameters to NULL.
+func (stmt *Statement) ClearBindings() {
+	C.sqlite3_clear_bindings(stmt.stmt)
+	for i := range stmt.bound {
+		stmt.bound[i] = false
+		stmt.values[i] = nil
+	}
+}
+
+// Sets the i-th parameter to NULL. SQLite can't unbind a single parameter, so unlike
+// ClearBindings it still counts as bound for strict binding.
+func (stmt *Statement) UnbindParameter(i int) error {
+	if s := C.sqlite3_bind_null(stmt.stmt, C.int(i)); s != C.SQLITE_OK {
+		return errors.New(C.GoString(C.sqlite3_errstr(s)))
+	}
+	stmt.markBound(i, nil)
+	return nil
+}
+
+// Returns how many times SQLite has automatically recompiled the statement after schema changes.
+func (stmt *Statement) RepreparedCount() int {
+	return int(C.sqlite3_stmt_status(stmt.stmt, C.SQLITE_STMTSTATUS_REPREPARE, 0))
+}
+
+// Returns the number of parameters.
+func (stmt *Statement) ParameterCount() int {
+	return int(C.sqlite3_bind_parameter_count(stmt.stmt))
+}
+
+// Returns the index of the named parameter (including its prefix, e.g. ":name"), or 0 if there's none.
+func (stmt *Statement) ParameterIndex(name string) int {
+	cs := C.CString(name)
+	defer C.free(unsafe.Pointer(cs))
+	return int(C.sqlite3_bind_parameter_index(stmt.stmt, cs))
+}
+
+// Enables or disables strict binding, in which stepping fails if any parameter hasn't been bound.
+func (stmt *Statement) SetStrictBinding(strict bool) {
+	stmt.strict = strict
+}
+
+// Records that the i-th parameter has been bound to the value.
+func (stmt *Statement) markBound(i int, val interface{}) {
+	if stmt.bound == nil {
+		n := stmt.ParameterCount()
+		stmt.bound = make([]bool, n)
+		stmt.values = make([]interface{}, n)
+	}
+	if i >= 1 && i <= len(stmt.bound) {
+		stmt.bound[i-1] = true
+		stmt.values[i-1] = val
+	}
+}
+
+// Returns the values most recently bound to the parameters in order (nil for unbound ones and zero blobs).
+func (stmt *Statement) BoundValues() []interface{} {
+	vals := make([]interface{}, stmt.ParameterCount())
+	copy(vals, stmt.values)
+	return vals
+}
+
+// Returns an error listing unbound parameters if strict binding is enabled.
+func (stmt *Statement) checkBindings() error {
+	if !stmt.strict {
+		return nil
+	}
+	var unbound []string
+	for i := 1; i <= stmt.ParameterCount(); i++ {
+		if i > len(stmt.bound) || !stmt.bound[i-1] {
+			unbound = append(unbound, strconv.Itoa(i))
+		}
+	}
+	if len(unbound) > 0 {
+		return fmt.Errorf("unbound parameters: %s", strings.Join(unbound, ", "))
+	}
+	return nil
+}
+
+// Resets the statement, binds the arguments and steps it to completion.
+func (stmt *Statement) ExecArgs(args ...interface{}) error {
+	stmt.Reset()
+	stmt.ClearBindings()
+	if err := stmt.BindAll(args...); err != nil {
+		return err
+	}
+	return stmt.Step()
 }
 
 // Moves on to the next row.
 func (stmt *Statement) Step() error {
+	if err := stmt.checkBindings(); err != nil {
+		return err
+	}
 	s := C.sqlite3_step(stmt.stmt)
 	if s != C.SQLITE_DONE {
 		return errors.New(C.GoString(C.sqlite3_errmsg(stmt.db.db)))
@@ -104,6 +646,9 @@ func (stmt *Statement) Step() error {
 
 // Enumerates all rows using the provided callback.
 func (stmt *Statement) StepRows(cb func()) error {
+	if err := stmt.checkBindings(); err != nil {
+		return err
+	}
 	for {
 		s := C.sqlite3_step(stmt.stmt)
 		if s == C.SQLITE_ROW {
@@ -117,6 +662,87 @@ func (stmt *Statement) StepRows(cb func()) error {
 	}
 }
 
+// Enumerates rows using the provided callback until it returns false.
+// Stopping early resets the statement and isn't treated as an error.
+func (stmt *Statement) StepRowsWhile(cb func() bool) error {
+	if err := stmt.checkBindings(); err != nil {
+		return err
+	}
+	for {
+		s := C.sqlite3_step(stmt.stmt)
+		if s == C.SQLITE_ROW {
+			if !cb() {
+				C.sqlite3_reset(stmt.stmt)
+				return nil
+			}
+		} else {
+			if s != C.SQLITE_DONE {
+				return errors.New("stepping through rows didn't finish with DONE")
+			}
+			return nil
+		}
+	}
+}
+
+// Enumerates at most max rows using the provided callback.
+// If more rows exist, the statement is reset and truncated is true.
+func (stmt *Statement) StepRowsLimit(max int, cb func()) (truncated bool, err error) {
+	n := 0
+	err = stmt.StepRowsWhile(func() bool {
+		if n == max {
+			truncated = true
+			return false
+		}
+		n++
+		cb()
+		return true
+	})
+	return truncated, err
+}
+
+// Returns the number of columns in the result.
+func (stmt *Statement) ColumnCount() int {
+	return int(C.sqlite3_column_count(stmt.stmt))
+}
+
+// Returns the names of the result columns.
+// The names are cached and refreshed only if SQLite recompiles the statement.
+func (stmt *Statement) Header() []string {
+	if n := stmt.RepreparedCount(); stmt.header == nil || n != stmt.headerPrep {
+		header := make([]string, stmt.ColumnCount())
+		for i := range header {
+			header[i] = stmt.ColumnName(i)
+		}
+		stmt.header, stmt.headerPrep = header, n
+	}
+	return stmt.header
+}
+
+// Returns the name of the i-th column.
+func (stmt *Statement) ColumnName(i int) string {
+	return C.GoString(C.sqlite3_column_name(stmt.stmt, C.int(i)))
+}
+
+// Returns true if the i-th column is NULL.
+func (stmt *Statement) ColumnIsNull(i int) bool {
+	return C.sqlite3_column_type(stmt.stmt, C.int(i)) == C.SQLITE_NULL
+}
+
+// Returns the i-th column as int64, float64, string, []byte or nil depending on its type.
+func (stmt *Statement) ColumnValue(i int) interface{} {
+	switch C.sqlite3_column_type(stmt.stmt, C.int(i)) {
+	case C.SQLITE_INTEGER:
+		return stmt.ColumnInt64(i)
+	case C.SQLITE_FLOAT:
+		return stmt.ColumnDouble(i)
+	case C.SQLITE_TEXT:
+		return stmt.ColumnText(i)
+	case C.SQLITE_BLOB:
+		return stmt.ColumnBlob(i)
+	}
+	return nil
+}
+
 // Returns the i-th column as int.
 func (stmt *Statement) ColumnInt(i int) int {
 	return int(C.sqlite3_column_int(stmt.stmt, C.int(i)))
@@ -138,6 +764,16 @@ func (stmt *Statement) ColumnText(i int) string {
 	return C.GoString(C.sqlite3_charptr(cs))
 }
 
+// Returns the i-th column as text read in SQLite's native UTF-16 encoding.
+func (stmt *Statement) ColumnText16(i int) string {
+	p := C.sqlite3_column_text16(stmt.stmt, C.int(i))
+	n := C.sqlite3_column_bytes16(stmt.stmt, C.int(i))
+	if p == nil || n == 0 {
+		return ""
+	}
+	return string(utf16.Decode(unsafe.Slice((*uint16)(p), int(n)/2)))
+}
+
 // Returns the i-th column as blob.
 func (stmt *Statement) ColumnBlob(i int) []byte {
 	p := C.sqlite3_column_blob(stmt.stmt, C.int(i))
@@ -147,16 +783,19 @@ func (stmt *Statement) ColumnBlob(i int) []byte {
 
 // Binds the i-th column as int.
 func (stmt *Statement) BindInt(i int, val int) {
+	stmt.markBound(i, val)
 	C.sqlite3_bind_int(stmt.stmt, C.int(i), C.int(val))
 }
 
 // Binds the i-th column as int64.
 func (stmt *Statement) BindInt64(i int, val int64) {
+	stmt.markBound(i, val)
 	C.sqlite3_bind_int64(stmt.stmt, C.int(i), C.sqlite3_int64(val))
 }
 
 // Binds the i-th column as double.
 func (stmt *Statement) BindDouble(i int, val float64) {
+	stmt.markBound(i, val)
 	C.sqlite3_bind_double(stmt.stmt, C.int(i), C.double(val))
 }
 
@@ -164,12 +803,244 @@ func (stmt *Statement) BindDouble(i int, val float64) {
 func (stmt *Statement) BindText(i int, val string) {
 	s := C.CString(val)
 	defer C.free(unsafe.Pointer(s))
+	stmt.markBound(i, val)
 	C.sqlite3_bind_text(stmt.stmt, C.int(i), s, -1, C.sqlite3_const_transient())
 }
 
+// Binds the i-th column as text passed to SQLite in its native UTF-16 encoding.
+func (stmt *Statement) BindText16(i int, val string) {
+	// The terminator keeps the slice non-empty for empty strings.
+	u := append(utf16.Encode([]rune(val)), 0)
+	stmt.markBound(i, val)
+	C.sqlite3_bind_text16(stmt.stmt, C.int(i), unsafe.Pointer(&u[0]), C.int(2*(len(u)-1)), C.sqlite3_const_transient())
+}
+
+// Text copied into C memory once so that it can be bound repeatedly without copying.
+type PreparedText struct {
+	text string
+	cs   *C.char
+	n    int
+}
+
+// Returns the text copied into C memory, which must be released with Free.
+func NewPreparedText(text string) *PreparedText {
+	return &PreparedText{text: text, cs: C.CString(text), n: len(text)}
+}
+
+// Returns the text.
+func (t *PreparedText) String() string {
+	return t.text
+}
+
+// Releases the C memory. The text mustn't be bound anymore and statements it's bound to
+// must be reset, rebound or closed first.
+func (t *PreparedText) Free() {
+	C.free(unsafe.Pointer(t.cs))
+	t.cs = nil
+}
+
+// Binds the i-th column as text without copying it (unlike BindText, which copies the string
+// to C memory and SQLite copies it again on every call). In hot loops binding the same large text
+// it saves a C allocation and both copies per call (see BenchmarkBindPreparedText).
+// The text must stay allocated while it's bound.
+func (stmt *Statement) BindPreparedText(i int, t *PreparedText) {
+	stmt.markBound(i, t.text)
+	C.sqlite3_bind_text(stmt.stmt, C.int(i), t.cs, C.int(t.n), C.sqlite3_const_static())
+}
+
 // Binds the i-th column as blob.
 func (stmt *Statement) BindBlob(i int, b []byte) {
 	p := C.CBytes(b)
 	defer C.free(p)
+	stmt.markBound(i, b)
 	C.sqlite3_bind_blob(stmt.stmt, C.int(i), p, C.int(len(b)), C.sqlite3_const_transient())
 }
+
+// Binds the i-th column as an 8-byte blob whose byte order matches the integer order.
+func (stmt *Statement) BindSortableInt64(i int, v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v)^(1<<63))
+	stmt.BindBlob(i, b[:])
+}
+
+// Returns the i-th column as int64 encoded by BindSortableInt64.
+func (stmt *Statement) ColumnSortableInt64(i int) int64 {
+	b := stmt.ColumnBlob(i)
+	if len(b) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b) ^ (1 << 63))
+}
+
+// Binds the i-th column as a duration stored in nanoseconds.
+func (stmt *Statement) BindDuration(i int, d time.Duration) {
+	stmt.BindInt64(i, int64(d))
+}
+
+// Returns the i-th column as a duration stored in nanoseconds.
+func (stmt *Statement) ColumnDuration(i int) time.Duration {
+	return time.Duration(stmt.ColumnInt64(i))
+}
+
+// Binds the i-th column as NULL.
+func (stmt *Statement) BindNull(i int) {
+	stmt.markBound(i, nil)
+	C.sqlite3_bind_null(stmt.stmt, C.int(i))
+}
+
+// Binds the i-th column using the method corresponding to the value's type.
+func (stmt *Statement) Bind(i int, val interface{}) error {
+	switch v := val.(type) {
+	case nil:
+		stmt.BindNull(i)
+	case int:
+		stmt.BindInt64(i, int64(v))
+	case int64:
+		stmt.BindInt64(i, v)
+	case float64:
+		stmt.BindDouble(i, v)
+	case time.Duration:
+		stmt.BindDuration(i, v)
+	case bool:
+		if v {
+			stmt.BindInt(i, 1)
+		} else {
+			stmt.BindInt(i, 0)
+		}
+	case string:
+		stmt.BindText(i, v)
+	case []byte:
+		stmt.BindBlob(i, v)
+	default:
+		return fmt.Errorf("can't bind value of type %T", val)
+	}
+	return nil
+}
+
+// Binds the values to the columns in order.
+func (stmt *Statement) BindAll(vals ...interface{}) error {
+	for i, val := range vals {
+		if err := stmt.Bind(i+1, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Binds positional values to the anonymous ? parameters in order and named values to the
+// parameters with those names (including the prefix, e.g. ":id" or "?3").
+// It returns an error unless every parameter ends up bound. SQLite can't tell anonymous
+// parameters from the unused indexes skipped by ?NNN, so the latter also consume positional values.
+func (stmt *Statement) BindMixed(positional []interface{}, named map[string]interface{}) error {
+	n := stmt.ParameterCount()
+	bound := make([]bool, n)
+	next := 0
+	for i := 1; i <= n; i++ {
+		if C.sqlite3_bind_parameter_name(stmt.stmt, C.int(i)) != nil {
+			continue
+		}
+		if next == len(positional) {
+			break
+		}
+		if err := stmt.Bind(i, positional[next]); err != nil {
+			return err
+		}
+		bound[i-1] = true
+		next++
+	}
+	if next < len(positional) {
+		return fmt.Errorf("too many positional values (%d)", len(positional))
+	}
+	for name, val := range named {
+		i := stmt.ParameterIndex(name)
+		if i == 0 {
+			return fmt.Errorf("no parameter named %s", name)
+		}
+		if err := stmt.Bind(i, val); err != nil {
+			return err
+		}
+		bound[i-1] = true
+	}
+	var unbound []string
+	for i, ok := range bound {
+		if !ok {
+			unbound = append(unbound, strconv.Itoa(i+1))
+		}
+	}
+	if len(unbound) > 0 {
+		return fmt.Errorf("unbound parameters: %s", strings.Join(unbound, ", "))
+	}
+	return nil
+}
+
+// Layouts tried when scanning text into time.Time.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Reads the i-th column into the value pointed to by dest.
+// Pointer-to-pointer destinations (e.g. **string) are set to nil for NULL.
+func (stmt *Statement) ColumnScan(i int, dest interface{}) error {
+	switch d := dest.(type) {
+	case *int:
+		*d = int(stmt.ColumnInt64(i))
+	case *int64:
+		*d = stmt.ColumnInt64(i)
+	case *float64:
+		*d = stmt.ColumnDouble(i)
+	case *string:
+		*d = stmt.ColumnText(i)
+	case *[]byte:
+		if stmt.ColumnIsNull(i) {
+			*d = nil
+		} else {
+			*d = stmt.ColumnBlob(i)
+		}
+	case *bool:
+		*d = stmt.ColumnInt64(i) != 0
+	case *time.Time:
+		t, err := stmt.columnTime(i)
+		if err != nil {
+			return err
+		}
+		*d = t
+	case *interface{}:
+		*d = stmt.ColumnValue(i)
+	default:
+		v := reflect.ValueOf(dest)
+		if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Ptr {
+			return fmt.Errorf("unsupported scan destination %T", dest)
+		}
+		if stmt.ColumnIsNull(i) {
+			v.Elem().Set(reflect.Zero(v.Elem().Type()))
+			return nil
+		}
+		p := reflect.New(v.Elem().Type().Elem())
+		if err := stmt.ColumnScan(i, p.Interface()); err != nil {
+			return err
+		}
+		v.Elem().Set(p)
+	}
+	return nil
+}
+
+// Returns the i-th column as time, stored either as text or as Unix time in seconds.
+func (stmt *Statement) columnTime(i int) (time.Time, error) {
+	switch C.sqlite3_column_type(stmt.stmt, C.int(i)) {
+	case C.SQLITE_NULL:
+		return time.Time{}, nil
+	case C.SQLITE_INTEGER, C.SQLITE_FLOAT:
+		return time.Unix(stmt.ColumnInt64(i), 0).UTC(), nil
+	}
+	s := stmt.ColumnText(i)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("couldn't parse time (%s)", s)
+}
@@ -0,0 +1,808 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns a new in-memory database closed when the test finishes.
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	db, err := NewDatabase(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+// Executes the SQL or fails the test.
+func mustExecute(t *testing.T, db *Database, sql string) {
+	t.Helper()
+	if err := db.Execute(sql); err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+}
+
+func TestReopenAfterFork(t *testing.T) {
+	db, err := NewDatabase(t.TempDir() + "/reopen.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mustExecute(t, db, "CREATE TABLE items (name TEXT)")
+	db.SetBusyTimeout(250 * time.Millisecond)
+	veto := errors.New("vetoed")
+	db.SetCommitGuard(func() error { return veto })
+	// Fast statements take 0ns at SQLite's millisecond resolution, a negative threshold logs them too.
+	var logged []string
+	db.SetSlowQueryLog(-1, func(sql string, d time.Duration) { logged = append(logged, sql) })
+	stmt, err := db.NewStatement("SELECT name FROM items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ReopenAfterFork(); err != nil {
+		t.Fatal(err)
+	}
+	// Closing a statement finalized by the reopen must be a no-op.
+	stmt.Close()
+	stmt.Close()
+	if err := db.AssertNoOpenStatements(); err != nil {
+		t.Error(err)
+	}
+	if d := db.BusyTimeout(); d != 250*time.Millisecond {
+		t.Errorf("got busy timeout %v, want 250ms", d)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExecute(t, db, "INSERT INTO items VALUES ('alpha')")
+	if err := tx.Commit(); err != veto {
+		t.Errorf("got commit error %v, want the guard's veto", err)
+	}
+	if len(logged) == 0 {
+		t.Error("the slow query log wasn't kept")
+	}
+}
+
+func TestBindPreparedText(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (n INTEGER, body TEXT)")
+	text := NewPreparedText(strings.Repeat("ab", 50000))
+	defer text.Free()
+	stmt, err := db.NewStatement("INSERT INTO items VALUES (?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		stmt.Reset()
+		stmt.BindInt(1, i)
+		stmt.BindPreparedText(2, text)
+		if err := stmt.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stmt.Close()
+	stmt, err = db.NewStatement("SELECT body FROM items ORDER BY n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	n := 0
+	if err := stmt.StepRows(func() {
+		if stmt.ColumnText(0) != text.String() {
+			t.Errorf("row %d: the text differs", n)
+		}
+		n++
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("got %d rows, want 3", n)
+	}
+}
+
+// Binds a 100 KB text and steps the statement b.N times.
+func benchmarkBindText(b *testing.B, bind func(*Statement, string, *PreparedText)) {
+	db, err := NewDatabase(":memory:")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+	s := strings.Repeat("ab", 50000)
+	text := NewPreparedText(s)
+	defer text.Free()
+	stmt, err := db.NewStatement("SELECT ?")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer stmt.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stmt.Reset()
+		bind(stmt, s, text)
+		if err := stmt.StepRows(func() {}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBindText(b *testing.B) {
+	benchmarkBindText(b, func(stmt *Statement, s string, _ *PreparedText) { stmt.BindText(1, s) })
+}
+
+func BenchmarkBindPreparedText(b *testing.B) {
+	benchmarkBindText(b, func(stmt *Statement, _ string, text *PreparedText) { stmt.BindPreparedText(1, text) })
+}
+
+func TestStepRowsWhile(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE nums (n INTEGER); INSERT INTO nums VALUES (1), (2), (3)")
+	stmt, err := db.NewStatement("SELECT n FROM nums ORDER BY n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	var seen []int
+	if err := stmt.StepRowsWhile(func() bool {
+		seen = append(seen, stmt.ColumnInt(0))
+		return false
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 1 || seen[0] != 1 {
+		t.Errorf("got rows %v, want only the first one", seen)
+	}
+	// The statement was reset, so stepping again starts over.
+	seen = nil
+	if err := stmt.StepRows(func() { seen = append(seen, stmt.ColumnInt(0)) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 3 || seen[0] != 1 {
+		t.Errorf("got rows %v after stopping early, want all three", seen)
+	}
+}
+
+// Returns the size of the -wal file of the database at path after writing to it in WAL mode and closing it.
+func walSizeAfterClose(t *testing.T, path string, opts *Options) int64 {
+	t.Helper()
+	db, err := Open(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A second connection keeps the WAL file from being deleted when the first one closes.
+	other, err := Open(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	mustExecute(t, db, "CREATE TABLE items (data BLOB); INSERT INTO items VALUES (randomblob(10000))")
+	db.Close()
+	fi, err := os.Stat(path + "-wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fi.Size()
+}
+
+func TestCheckpointOnClose(t *testing.T) {
+	dir := t.TempDir()
+	if n := walSizeAfterClose(t, dir+"/default.db", &Options{JournalMode: JournalWAL}); n != 0 {
+		t.Errorf("the -wal file has %d bytes after Close, want 0", n)
+	}
+	if n := walSizeAfterClose(t, dir+"/skipped.db", &Options{JournalMode: JournalWAL, NoCheckpointOnClose: true}); n == 0 {
+		t.Error("the -wal file was emptied although the checkpoint was disabled")
+	}
+}
+
+func TestOpenConnectionCount(t *testing.T) {
+	base := OpenConnectionCount()
+	a, err := NewDatabase(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewDatabase(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	a.Close()
+	if n := OpenConnectionCount() - base; n != 1 {
+		t.Errorf("got %d open connections after opening two and closing one, want 1", n)
+	}
+}
+
+func TestExecArgsReuse(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE nums (n INTEGER, label TEXT)")
+	stmt, err := db.NewStatement("INSERT INTO nums VALUES (?, ?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if err := stmt.ExecArgs(i, "n"+strconv.Itoa(i)); err != nil {
+			t.Fatalf("row %d: %v", i, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	n, err := db.queryInt64("SELECT count(*) FROM nums WHERE label = 'n' || n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := db.queryInt64("SELECT sum(n) FROM nums")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1000 || sum != 999*1000/2 {
+		t.Errorf("got %d matching rows with sum %d, want 1000 rows with sum %d", n, sum, 999*1000/2)
+	}
+}
+
+func TestEnterMutexNests(t *testing.T) {
+	if !CompileOptionUsed("THREADSAFE=1") {
+		t.Skip("SQLite isn't in serialized mode")
+	}
+	db := newTestDatabase(t)
+	db.EnterMutex()
+	db.EnterMutex()
+	db.LeaveMutex()
+	entered := make(chan struct{})
+	go func() {
+		db.EnterMutex()
+		close(entered)
+		db.LeaveMutex()
+	}()
+	select {
+	case <-entered:
+		t.Fatal("the mutex was released after one of two LeaveMutex calls")
+	case <-time.After(50 * time.Millisecond):
+	}
+	db.LeaveMutex()
+	select {
+	case <-entered:
+	case <-time.After(time.Second):
+		t.Fatal("the mutex wasn't released after the second LeaveMutex call")
+	}
+}
+
+func TestColumnScan(t *testing.T) {
+	db := newTestDatabase(t)
+	stmt, err := db.NewStatement("SELECT 42, 2.5, 'text', x'0102', 1, '2020-01-02 03:04:05', 1577934245, NULL, 'set'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	var (
+		i       int
+		i64     int64
+		f       float64
+		s       string
+		b       []byte
+		ok      bool
+		tm, tm2 time.Time
+		null    = new(string)
+		set     *string
+		v       interface{}
+	)
+	scans := []struct {
+		col  int
+		dest interface{}
+	}{{0, &i}, {0, &i64}, {1, &f}, {2, &s}, {3, &b}, {4, &ok}, {5, &tm}, {6, &tm2}, {7, &null}, {8, &set}, {1, &v}}
+	if err := stmt.StepRows(func() {
+		for _, scan := range scans {
+			if err := stmt.ColumnScan(scan.col, scan.dest); err != nil {
+				t.Errorf("column %d into %T: %v", scan.col, scan.dest, err)
+			}
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if i != 42 || i64 != 42 || f != 2.5 || s != "text" || !bytes.Equal(b, []byte{1, 2}) || !ok {
+		t.Errorf("got %v %v %v %q %v %v", i, i64, f, s, b, ok)
+	}
+	if !tm.Equal(want) || !tm2.Equal(want) {
+		t.Errorf("got times %v and %v, want %v", tm, tm2, want)
+	}
+	if null != nil {
+		t.Errorf("got %q for NULL, want a nil pointer", *null)
+	}
+	if set == nil || *set != "set" {
+		t.Errorf("got %v for a **string, want set", set)
+	}
+	if v != 2.5 {
+		t.Errorf("got %#v for an interface{}, want 2.5", v)
+	}
+	var unsupported uint8
+	if err := stmt.ColumnScan(0, &unsupported); err == nil {
+		t.Error("scanning into *uint8 succeeded")
+	}
+}
+
+func TestSortableInt64(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE keys (k BLOB)")
+	stmt, err := db.NewStatement("INSERT INTO keys VALUES (?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vals := []int64{5, -1, math.MaxInt64, 0, math.MinInt64, -300, 256}
+	for _, v := range vals {
+		stmt.Reset()
+		stmt.BindSortableInt64(1, v)
+		if err := stmt.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stmt.Close()
+	stmt, err = db.NewStatement("SELECT k FROM keys ORDER BY k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	var got []int64
+	if err := stmt.StepRows(func() { got = append(got, stmt.ColumnSortableInt64(0)) }); err != nil {
+		t.Fatal(err)
+	}
+	want := []int64{math.MinInt64, -300, -1, 0, 5, 256, math.MaxInt64}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNewTempDatabase(t *testing.T) {
+	db, err := NewTempDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mustExecute(t, db, "CREATE TABLE items (x); INSERT INTO items VALUES (1)")
+	if n, err := db.queryInt64("SELECT count(*) FROM items"); err != nil || n != 1 {
+		t.Errorf("got %d rows (%v), want 1", n, err)
+	}
+	if name := db.Filename(); name != "" {
+		t.Errorf("got filename %q, want none", name)
+	}
+}
+
+func TestSetStrictBinding(t *testing.T) {
+	db := newTestDatabase(t)
+	stmt, err := db.NewStatement("SELECT ?, ?, ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	stmt.BindInt(2, 7)
+	if err := stmt.StepRows(func() {}); err != nil {
+		t.Fatalf("non-strict step failed: %v", err)
+	}
+	stmt.Reset()
+	stmt.SetStrictBinding(true)
+	err = stmt.StepRows(func() {})
+	if err == nil || !strings.Contains(err.Error(), "1, 3") {
+		t.Fatalf("got %v, want an error listing parameters 1 and 3", err)
+	}
+	stmt.Reset()
+	stmt.BindNull(1)
+	stmt.BindText(3, "x")
+	if err := stmt.StepRows(func() {}); err != nil {
+		t.Errorf("strict step with all parameters bound failed: %v", err)
+	}
+}
+
+func TestURIParameter(t *testing.T) {
+	db, err := NewDatabase("file:" + t.TempDir() + "/uri.db?cache=private&app_mode=replica&verbose=yes&retries=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if v, ok := db.URIParameter("app_mode"); !ok || v != "replica" {
+		t.Errorf("got app_mode %q (%v), want replica", v, ok)
+	}
+	if _, ok := db.URIParameter("missing"); ok {
+		t.Error("a missing parameter was found")
+	}
+	if !db.URIBoolean("verbose", false) || db.URIBoolean("missing", false) {
+		t.Error("wrong boolean parameters")
+	}
+	if n := db.URIInt64("retries", 0); n != 3 {
+		t.Errorf("got retries %d, want 3", n)
+	}
+	if n := db.URIInt64("missing", 7); n != 7 {
+		t.Errorf("got the default %d, want 7", n)
+	}
+}
+
+func TestBoundValues(t *testing.T) {
+	db := newTestDatabase(t)
+	stmt, err := db.NewStatement("SELECT ?, ?, ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	stmt.BindText(1, "alpha")
+	stmt.BindInt(2, 42)
+	vals := stmt.BoundValues()
+	if len(vals) != 3 || vals[0] != "alpha" || vals[1] != 42 || vals[2] != nil {
+		t.Errorf("got bound values %#v, want [alpha 42 <nil>]", vals)
+	}
+}
+
+func TestBindDuration(t *testing.T) {
+	db := newTestDatabase(t)
+	stmt, err := db.NewStatement("SELECT ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	for _, d := range []time.Duration{0, time.Hour, -90 * time.Second, time.Duration(math.MaxInt64)} {
+		stmt.Reset()
+		stmt.BindDuration(1, d)
+		var got time.Duration
+		if err := stmt.StepRows(func() { got = stmt.ColumnDuration(0) }); err != nil {
+			t.Fatal(err)
+		}
+		if got != d {
+			t.Errorf("got %v, want %v", got, d)
+		}
+	}
+}
+
+func TestRepreparedCount(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (name TEXT); INSERT INTO items VALUES ('alpha')")
+	stmt, err := db.NewStatement("SELECT * FROM items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if err := stmt.StepRows(func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if n := stmt.RepreparedCount(); n != 0 {
+		t.Errorf("got %d reprepares before the schema change, want 0", n)
+	}
+	mustExecute(t, db, "ALTER TABLE items ADD COLUMN qty INTEGER")
+	stmt.Reset()
+	if err := stmt.StepRows(func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if n := stmt.RepreparedCount(); n != 1 {
+		t.Errorf("got %d reprepares after the schema change, want 1", n)
+	}
+}
+
+func TestStepRowsLimit(t *testing.T) {
+	db := newTestDatabase(t)
+	createNums(t, db, 10)
+	stmt, err := db.NewStatement("SELECT n FROM nums ORDER BY n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	// Each call starts from the first row as a truncated enumeration resets the statement.
+	for _, c := range []struct {
+		max       int
+		truncated bool
+		rows      int
+	}{{3, true, 3}, {10, false, 10}, {20, false, 10}} {
+		var rows []int
+		truncated, err := stmt.StepRowsLimit(c.max, func() { rows = append(rows, stmt.ColumnInt(0)) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if truncated != c.truncated || len(rows) != c.rows || rows[0] != 1 {
+			t.Errorf("limit %d: got truncated %v and rows %v, want %v and %d rows from 1", c.max, truncated, rows, c.truncated, c.rows)
+		}
+	}
+}
+
+func TestHeader(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (id INTEGER, name TEXT); INSERT INTO items VALUES (1, 'alpha'), (2, 'beta')")
+	stmt, err := db.NewStatement("SELECT * FROM items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	want := []string{"id", "name"}
+	if err := stmt.StepRows(func() {
+		if h := stmt.Header(); !reflect.DeepEqual(h, want) {
+			t.Errorf("got header %v, want %v", h, want)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Recompiling after a schema change refreshes the cached names.
+	mustExecute(t, db, "ALTER TABLE items ADD COLUMN qty INTEGER")
+	stmt.Reset()
+	want = append(want, "qty")
+	if err := stmt.StepRows(func() {
+		if h := stmt.Header(); !reflect.DeepEqual(h, want) {
+			t.Errorf("got header %v after the schema change, want %v", h, want)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBindMixed(t *testing.T) {
+	db := newTestDatabase(t)
+	stmt, err := db.NewStatement("SELECT ?, :name, ?, ?4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if err := stmt.BindMixed([]interface{}{"first", 2}, map[string]interface{}{":name": "named", "?4": 4.5}); err != nil {
+		t.Fatal(err)
+	}
+	var row []interface{}
+	if err := stmt.StepRows(func() {
+		for i := 0; i < stmt.ColumnCount(); i++ {
+			row = append(row, stmt.ColumnValue(i))
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(row, []interface{}{"first", "named", int64(2), 4.5}) {
+		t.Errorf("got row %#v, want [first named 2 4.5]", row)
+	}
+	stmt.Reset()
+	if err := stmt.BindMixed([]interface{}{"first", 2}, map[string]interface{}{":name": "named"}); err == nil || !strings.Contains(err.Error(), "unbound parameters: 4") {
+		t.Errorf("got %v, want parameter 4 reported unbound", err)
+	}
+	if err := stmt.BindMixed([]interface{}{1, 2, 3}, nil); err == nil {
+		t.Error("binding too many positional values succeeded")
+	}
+}
+
+func TestUnbindParameter(t *testing.T) {
+	db := newTestDatabase(t)
+	stmt, err := db.NewStatement("SELECT ?, ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	stmt.SetStrictBinding(true)
+	stmt.BindText(1, "alpha")
+	stmt.BindInt(2, 7)
+	if err := stmt.UnbindParameter(2); err != nil {
+		t.Fatal(err)
+	}
+	var first string
+	var null bool
+	if err := stmt.StepRows(func() { first, null = stmt.ColumnText(0), stmt.ColumnIsNull(1) }); err != nil {
+		t.Fatal(err)
+	}
+	if first != "alpha" || !null {
+		t.Errorf("got %q and null %v, want alpha and NULL", first, null)
+	}
+	if err := stmt.UnbindParameter(3); err == nil {
+		t.Error("unbinding a parameter out of range succeeded")
+	}
+}
+
+func TestSafeExecuteDeepExpression(t *testing.T) {
+	db := newTestDatabase(t)
+	const depth = 100000
+	sql := "SELECT " + strings.Repeat("(", depth) + "1" + strings.Repeat(")", depth)
+	if err := db.SafeExecute(sql); err == nil {
+		t.Fatal("an expression nested beyond SQLite's limits succeeded")
+	}
+	if err := db.SafeExecute("SELECT 1"); err != nil {
+		t.Errorf("the connection is unusable after the error: %v", err)
+	}
+}
+
+func TestSetLastInsertRowID(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (name TEXT); INSERT INTO items VALUES ('alpha')")
+	if id := db.LastInsertRowID(); id != 1 {
+		t.Fatalf("got last insert rowid %d, want 1", id)
+	}
+	db.SetLastInsertRowID(4242)
+	if id := db.LastInsertRowID(); id != 4242 {
+		t.Errorf("got last insert rowid %d, want 4242", id)
+	}
+	if n, _ := db.queryInt64("SELECT last_insert_rowid()"); n != 4242 {
+		t.Errorf("got last_insert_rowid() %d, want 4242", n)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	path := t.TempDir() + "/readonly.db"
+	if _, err := Open(path, &Options{ReadOnly: true}); err == nil {
+		t.Fatal("opening a missing file read-only succeeded")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("opening read-only created the file (%v)", err)
+	}
+	db, err := NewDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExecute(t, db, "CREATE TABLE items (name TEXT); INSERT INTO items VALUES ('alpha')")
+	db.Close()
+	db, err = Open(path, &Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if n, err := db.queryInt64("SELECT count(*) FROM items"); err != nil || n != 1 {
+		t.Errorf("got %d rows (%v), want 1", n, err)
+	}
+	if err := db.Execute("INSERT INTO items VALUES ('beta')"); err == nil || !strings.Contains(err.Error(), "readonly") {
+		t.Errorf("got %v writing to a read-only database, want a readonly error", err)
+	}
+}
+
+func TestSameDatabase(t *testing.T) {
+	dir := t.TempDir()
+	open := func(path string) *Database {
+		db, err := NewDatabase(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(db.Close)
+		return db
+	}
+	a, b, other := open(dir+"/same.db"), open(dir+"/./same.db"), open(dir+"/other.db")
+	if err := os.Symlink(dir+"/same.db", dir+"/link.db"); err != nil {
+		t.Fatal(err)
+	}
+	link := open(dir + "/link.db")
+	memory := newTestDatabase(t)
+	for _, c := range []struct {
+		name string
+		a, b *Database
+		want bool
+	}{
+		{"same file", a, b, true},
+		{"symlink", a, link, true},
+		{"different files", a, other, false},
+		{"in-memory", memory, newTestDatabase(t), false},
+		{"in-memory itself", memory, memory, false},
+	} {
+		if same, err := SameDatabase(c.a, c.b); err != nil || same != c.want {
+			t.Errorf("%s: got %v (%v), want %v", c.name, same, err, c.want)
+		}
+	}
+}
+
+func TestExecuteScriptArgs(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (name TEXT, qty INTEGER)")
+	script := `INSERT INTO items VALUES (?, ?);
+		-- a comment between statements
+		UPDATE items SET qty = qty + ? WHERE name = ?;`
+	if err := db.ExecuteScriptArgs(script, [][]interface{}{{"alpha", 1}, {41, "alpha"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := db.queryInt64("SELECT qty FROM items WHERE name = 'alpha'"); n != 42 {
+		t.Errorf("got qty %d, want 42", n)
+	}
+	// A failing statement rolls back the statements before it.
+	err := db.ExecuteScriptArgs("INSERT INTO items VALUES (?, ?); INSERT INTO missing VALUES (?)", [][]interface{}{{"beta", 2}, {3}})
+	if err == nil || !strings.Contains(err.Error(), "statement 2") {
+		t.Errorf("got %v, want an error for statement 2", err)
+	}
+	if n, _ := db.queryInt64("SELECT count(*) FROM items"); n != 1 {
+		t.Errorf("got %d rows after the failed script, want 1", n)
+	}
+}
+
+func TestCloneOn(t *testing.T) {
+	a, b := walDatabases(t)
+	stmt, err := a.NewStatement("SELECT count(*) FROM items WHERE name = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	clone, err := stmt.CloneOn(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clone.Close()
+	if clone.db != b {
+		t.Error("the clone isn't prepared on the other connection")
+	}
+	// The row inserted by b's open transaction is only visible to b.
+	mustExecute(t, b, "BEGIN; INSERT INTO items VALUES ('beta')")
+	defer mustExecute(t, b, "ROLLBACK")
+	count := func(stmt *Statement) int64 {
+		t.Helper()
+		var n int64
+		stmt.BindText(1, "beta")
+		if err := stmt.StepRows(func() { n = stmt.ColumnInt64(0) }); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := count(stmt); n != 0 {
+		t.Errorf("the original counted %d rows, want 0", n)
+	}
+	if n := count(clone); n != 1 {
+		t.Errorf("the clone counted %d rows, want 1", n)
+	}
+	if _, err := stmt.CloneOn(newTestDatabase(t)); err == nil {
+		t.Error("cloning onto a connection without the table succeeded")
+	}
+}
+
+func TestText16(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "PRAGMA encoding = 'UTF-16le'; CREATE TABLE t (x TEXT)")
+	const text = "héllo \U0001F600 世界"
+	stmt, err := db.NewStatement("INSERT INTO t VALUES (?), ('\U0001D11E')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt.BindText16(1, text)
+	err = stmt.Step()
+	stmt.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The emoji is a surrogate pair in UTF-16, so it's stored as 4 bytes.
+	want := int64(2 * (len([]rune(text)) + 1))
+	if n, err := db.queryInt64("SELECT length(CAST(x AS BLOB)) FROM t WHERE rowid = 1"); err != nil || n != want {
+		t.Errorf("got %d stored bytes (%v), want %d", n, err, want)
+	}
+	stmt, err = db.NewStatement("SELECT x FROM t ORDER BY rowid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	var got []string
+	if err := stmt.StepRows(func() { got = append(got, stmt.ColumnText16(0)) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != text || got[1] != "\U0001D11E" {
+		t.Errorf("got %q, want [%q %q]", got, text, "\U0001D11E")
+	}
+}
+
+func TestLiveDatabases(t *testing.T) {
+	before := LiveDatabases()
+	dir := t.TempDir()
+	var dbs []*Database
+	for _, path := range []string{filepath.Join(dir, "a.db"), filepath.Join(dir, "b.db"), ":memory:"} {
+		db, err := NewDatabase(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbs = append(dbs, db)
+	}
+	dbs[1].Close()
+	defer dbs[0].Close()
+	defer dbs[2].Close()
+	after := LiveDatabases()
+	if len(after) != len(before)+2 {
+		t.Fatalf("got %q, want two more connections than %q", after, before)
+	}
+	added := after[len(before):]
+	if added[0] != filepath.Join(dir, "a.db") || !strings.HasPrefix(added[1], "memory#") {
+		t.Errorf("got %q, want a.db and an in-memory database", added)
+	}
+}
@@ -0,0 +1,278 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Returns the column name of a struct field (from the db tag or the field name), or "" if it's skipped.
+func fieldColumn(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+	tag := f.Tag.Get("db")
+	if tag == "-" {
+		return ""
+	}
+	if tag != "" {
+		return tag
+	}
+	return strings.ToLower(f.Name)
+}
+
+// A struct field mapped to a column.
+type structColumn struct {
+	index  int
+	column string
+}
+
+// Returns the fields of the struct type mapped to columns.
+func structColumns(t reflect.Type) []structColumn {
+	var cols []structColumn
+	for i := 0; i < t.NumField(); i++ {
+		if name := fieldColumn(t.Field(i)); name != "" {
+			cols = append(cols, structColumn{i, name})
+		}
+	}
+	return cols
+}
+
+// Binds the fields of the struct (or pointer to struct) to the named parameters (:column) of the statement.
+// Fields without a corresponding parameter are skipped.
+func (stmt *Statement) BindStruct(src interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(src))
+	if v.Kind() != reflect.Struct {
+		return errors.New("bind source must be a struct")
+	}
+	for _, col := range structColumns(v.Type()) {
+		i := stmt.ParameterIndex(":" + col.column)
+		if i == 0 {
+			continue
+		}
+		if err := stmt.bindValue(i, v.Field(col.index)); err != nil {
+			return fmt.Errorf("field %s: %v", v.Type().Field(col.index).Name, err)
+		}
+	}
+	return nil
+}
+
+// Binds the i-th column using the value's underlying kind.
+func (stmt *Statement) bindValue(i int, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		stmt.BindInt64(i, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		stmt.BindInt64(i, int64(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		stmt.BindDouble(i, v.Float())
+	case reflect.Bool:
+		return stmt.Bind(i, v.Bool())
+	case reflect.String:
+		stmt.BindText(i, v.String())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		if v.IsNil() {
+			stmt.BindNull(i)
+		} else {
+			stmt.BindBlob(i, v.Bytes())
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// Reads the current row into the struct pointed to by dest, matching columns to fields.
+// Fields are matched by their db tags or case-insensitively by name. NULLs are scanned as zero values.
+func (stmt *Statement) ScanStruct(dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("scan destination must be a pointer to a struct")
+	}
+	v = v.Elem()
+	fields := make(map[string]int)
+	for _, col := range structColumns(v.Type()) {
+		fields[strings.ToLower(col.column)] = col.index
+	}
+	for i := 0; i < stmt.ColumnCount(); i++ {
+		fi, ok := fields[strings.ToLower(stmt.ColumnName(i))]
+		if !ok {
+			continue
+		}
+		if err := stmt.scanValue(i, v.Field(fi)); err != nil {
+			return fmt.Errorf("column %s: %v", stmt.ColumnName(i), err)
+		}
+	}
+	return nil
+}
+
+// Reads the i-th column into the value using its underlying kind.
+func (stmt *Statement) scanValue(i int, v reflect.Value) error {
+	if stmt.ColumnIsNull(i) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(stmt.ColumnInt64(i))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(stmt.ColumnInt64(i)))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(stmt.ColumnDouble(i))
+	case reflect.Bool:
+		v.SetBool(stmt.ColumnInt64(i) != 0)
+	case reflect.String:
+		v.SetString(stmt.ColumnText(i))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		v.SetBytes(stmt.ColumnBlob(i))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// Steps through the statement and scans each row into a T.
+func ScanAll[T any](stmt *Statement) ([]T, error) {
+	rows := []T{}
+	var err error
+	serr := stmt.StepRowsWhile(func() bool {
+		var row T
+		if err = stmt.ScanStruct(&row); err != nil {
+			return false
+		}
+		rows = append(rows, row)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if serr != nil {
+		return nil, serr
+	}
+	return rows, nil
+}
+
+// Steps through the statement and scans rows into the elements of dst in order until it's full,
+// returning the number of rows scanned. The statement is reset if rows remain.
+func ScanInto[T any](stmt *Statement, dst []T) (n int, err error) {
+	var zero T
+	serr := stmt.StepRowsWhile(func() bool {
+		if n == len(dst) {
+			return false
+		}
+		dst[n] = zero
+		if err = stmt.ScanStruct(&dst[n]); err != nil {
+			return false
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, serr
+}
+
+// Inserts the elements of a slice of structs (or pointers to structs) into the table in one transaction
+// and returns the number of rows inserted. Fields are mapped to columns as in BindStruct.
+// As in Repo.Insert, a zero id field is left out so that SQLite assigns the id.
+func (db *Database) BulkInsertStructs(table string, records interface{}) (int64, error) {
+	v := reflect.ValueOf(records)
+	if v.Kind() != reflect.Slice {
+		return 0, errors.New("records must be a slice")
+	}
+	t := v.Type().Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return 0, errors.New("records must be a slice of structs")
+	}
+	cols := structColumns(t)
+	if len(cols) == 0 {
+		return 0, errors.New("the struct has no mapped fields")
+	}
+	id := -1
+	for i, col := range cols {
+		if strings.EqualFold(col.column, "id") {
+			id = i
+		}
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	// The statements with all columns and without the id, prepared when first needed.
+	var stmts [2]*Statement
+	defer func() {
+		for _, stmt := range stmts {
+			if stmt != nil {
+				stmt.Close()
+			}
+		}
+	}()
+	for n := 0; n < v.Len(); n++ {
+		rec := reflect.Indirect(v.Index(n))
+		if !rec.IsValid() {
+			tx.Rollback()
+			return 0, &RowError{Index: n, Err: errors.New("nil record")}
+		}
+		skip := -1
+		if id >= 0 && rec.Field(cols[id].index).IsZero() {
+			skip = id
+		}
+		k := 0
+		if skip >= 0 {
+			k = 1
+		}
+		if stmts[k] == nil {
+			var names []string
+			for i, col := range cols {
+				if i != skip {
+					names = append(names, QuoteIdentifier(col.column))
+				}
+			}
+			sql := "INSERT INTO " + QuoteIdentifier(table) + " DEFAULT VALUES"
+			if len(names) > 0 {
+				sql = "INSERT INTO " + QuoteIdentifier(table) + " (" + strings.Join(names, ", ") + ") VALUES (?" + strings.Repeat(", ?", len(names)-1) + ")"
+			}
+			if stmts[k], err = tx.NewStatement(sql); err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+		}
+		stmt := stmts[k]
+		stmt.Reset()
+		p := 1
+		for i, col := range cols {
+			if i == skip {
+				continue
+			}
+			if err := stmt.bindValue(p, rec.Field(col.index)); err != nil {
+				tx.Rollback()
+				return 0, &RowError{Index: n, Err: fmt.Errorf("field %s: %v", t.Field(col.index).Name, err)}
+			}
+			p++
+		}
+		if err := stmt.Step(); err != nil {
+			tx.Rollback()
+			return 0, &RowError{Index: n, Err: err}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return int64(v.Len()), nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"fmt"
+	"testing"
+)
+
+type testItem struct {
+	ID   int64
+	Name string
+}
+
+func TestBulkInsertStructsZeroID(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)")
+	items := []testItem{{Name: "alpha"}, {ID: 10, Name: "beta"}, {Name: "gamma"}}
+	n, err := db.BulkInsertStructs("items", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("got %d rows inserted, want 3", n)
+	}
+	stmt, err := db.NewStatement("SELECT id, name FROM items ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	rows, err := ScanAll[testItem](stmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []testItem{{1, "alpha"}, {10, "beta"}, {11, "gamma"}}
+	if len(rows) != len(want) {
+		t.Fatalf("got %v, want %v", rows, want)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d: got %v, want %v", i, rows[i], want[i])
+		}
+	}
+}
+
+type testColor int
+
+type testSize string
+
+type testPaint struct {
+	Name  string
+	Color testColor
+	Size  testSize
+}
+
+func TestStructNamedTypes(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE paints (name TEXT, color INTEGER, size TEXT)")
+	stmt, err := db.NewStatement("INSERT INTO paints VALUES (:name, :color, :size)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testPaint{"ochre", testColor(3), testSize("large")}
+	if err := stmt.BindStruct(want); err != nil {
+		t.Fatal(err)
+	}
+	err = stmt.Step()
+	stmt.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ, _ := db.queryInt64("SELECT typeof(color) = 'integer' FROM paints"); typ != 1 {
+		t.Error("the named integer type wasn't stored as an integer")
+	}
+	stmt, err = db.NewStatement("SELECT name, color, size FROM paints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	var got testPaint
+	if err := stmt.StepRows(func() {
+		if err := stmt.ScanStruct(&got); err != nil {
+			t.Error(err)
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestScanAll(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT); INSERT INTO items VALUES (1, 'alpha'), (2, 'beta')")
+	stmt, err := db.NewStatement("SELECT id, name FROM items WHERE id > ? ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	stmt.BindInt(1, 0)
+	rows, err := ScanAll[testItem](stmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0] != (testItem{1, "alpha"}) || rows[1] != (testItem{2, "beta"}) {
+		t.Errorf("got %v", rows)
+	}
+	stmt.Reset()
+	stmt.BindInt(1, 5)
+	rows, err = ScanAll[testItem](stmt)
+	if err != nil || rows == nil || len(rows) != 0 {
+		t.Errorf("got %#v (%v) for no rows, want an empty slice", rows, err)
+	}
+}
+
+func TestScanInto(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, `CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT);
+		WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 10)
+		INSERT INTO items SELECT i, 'item ' || i FROM n`)
+	stmt, err := db.NewStatement("SELECT id, name FROM items ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	dst := make([]testItem, 5)
+	n, err := ScanInto(stmt, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Fatalf("got %d rows, want 5", n)
+	}
+	for i, item := range dst {
+		if item.ID != int64(i+1) || item.Name != fmt.Sprintf("item %d", i+1) {
+			t.Errorf("row %d: got %v", i, item)
+		}
+	}
+	// The statement was reset, so the next call starts over.
+	big := make([]testItem, 20)
+	if n, err := ScanInto(stmt, big); err != nil || n != 10 || big[0].ID != 1 {
+		t.Errorf("got %d rows (%v) starting at %d, want 10 starting at 1", n, err, big[0].ID)
+	}
+}
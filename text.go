@@ -0,0 +1,69 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <stdlib.h>
+#include <sqlite3.h>
+*/
+import "C"
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// Returns true if the string matches the pattern using SQLite's GLOB semantics.
+func GlobMatch(pattern, s string) bool {
+	cp := C.CString(pattern)
+	defer C.free(unsafe.Pointer(cp))
+	cs := C.CString(s)
+	defer C.free(unsafe.Pointer(cs))
+	return C.sqlite3_strglob(cp, cs) == 0
+}
+
+// Returns true if the string matches the pattern using SQLite's LIKE semantics
+// with the given escape character (0 for none).
+func LikeMatch(pattern, s string, escape rune) bool {
+	cp := C.CString(pattern)
+	defer C.free(unsafe.Pointer(cp))
+	cs := C.CString(s)
+	defer C.free(unsafe.Pointer(cs))
+	return C.sqlite3_strlike(cp, cs, C.uint(escape)) == 0
+}
+
+// Compares two strings like SQLite's BINARY collation (bytewise), returning -1, 0 or 1.
+func CompareText(a, b string) int {
+	return strings.Compare(a, b)
+}
+
+// Compares two strings like SQLite's NOCASE collation, returning -1, 0 or 1.
+// Only ASCII letters are folded, unlike strings.EqualFold.
+func CompareNoCase(a, b string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		ca, cb := asciiLower(a[i]), asciiLower(b[i])
+		if ca != cb {
+			if ca < cb {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	}
+	return 0
+}
+
+// Lowers an ASCII letter, leaving all other bytes (including those of non-ASCII letters) unchanged.
+func asciiLower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
@@ -0,0 +1,71 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import "testing"
+
+func TestGlobAndLikeMatch(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"a*c", "abbc", true},
+		{"a?c", "abc", true},
+		{"a?c", "ABC", false},
+		{"[0-9]*", "7up", true},
+		{"[0-9]*", "up", false},
+	} {
+		if got := GlobMatch(tc.pattern, tc.s); got != tc.want {
+			t.Errorf("GlobMatch(%q, %q) = %v, want %v", tc.pattern, tc.s, got, tc.want)
+		}
+	}
+	for _, tc := range []struct {
+		pattern, s string
+		escape     rune
+		want       bool
+	}{
+		{"a%", "ABC", 0, true},
+		{"a_c", "abc", 0, true},
+		{"a_c", "abbc", 0, false},
+		{"100!%", "100%", '!', true},
+		{"100!%", "1000", '!', false},
+		{"a!_c", "abc", '!', false},
+	} {
+		if got := LikeMatch(tc.pattern, tc.s, tc.escape); got != tc.want {
+			t.Errorf("LikeMatch(%q, %q, %q) = %v, want %v", tc.pattern, tc.s, tc.escape, got, tc.want)
+		}
+	}
+}
+
+func TestCompareTextAndNoCase(t *testing.T) {
+	db := newTestDatabase(t)
+	// Returns SQLite's comparison of a and b in the collation.
+	sqliteCompare := func(a, b, collation string) int {
+		n, err := db.queryInt64("SELECT (?1 > ?2 COLLATE "+collation+") - (?1 < ?2 COLLATE "+collation+")", a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return int(n)
+	}
+	for _, tc := range [][2]string{
+		{"abc", "ABD"},
+		{"a", "A"},
+		{"_", "a"},
+		{"_", "A"},
+		{"É", "é"},      // folded by strings.EqualFold but not by NOCASE
+		{"K", "\u212a"}, // the Kelvin sign folds to K in Go
+		{"straße", "STRASSE"},
+		{"ab", "a"},
+		{"", ""},
+	} {
+		a, b := tc[0], tc[1]
+		if got, want := CompareText(a, b), sqliteCompare(a, b, "BINARY"); got != want {
+			t.Errorf("CompareText(%q, %q) = %d, SQLite says %d", a, b, got, want)
+		}
+		if got, want := CompareNoCase(a, b), sqliteCompare(a, b, "NOCASE"); got != want {
+			t.Errorf("CompareNoCase(%q, %q) = %d, SQLite says %d", a, b, got, want)
+		}
+	}
+}
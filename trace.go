@@ -0,0 +1,83 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+#include <sqlite3.h>
+extern void goSlowQuery(uintptr_t, char*, sqlite3_int64);
+
+typedef struct {
+	uintptr_t handle;
+	sqlite3_int64 threshold;
+} slow_log;
+
+// Reports statements that ran for longer than the threshold (in nanoseconds).
+static int slow_log_profile(unsigned mask, void* ctx, void* p, void* x) {
+	slow_log* s = ctx;
+	sqlite3_int64 ns = *(sqlite3_int64*)x;
+	if (mask == SQLITE_TRACE_PROFILE && ns > s->threshold) {
+		goSlowQuery(s->handle, (char*)sqlite3_sql((sqlite3_stmt*)p), ns);
+	}
+	return 0;
+}
+
+static inline int sqlite3_slow_log(sqlite3* db, slow_log* s) {
+	return sqlite3_trace_v2(db, s ? SQLITE_TRACE_PROFILE : 0, s ? slow_log_profile : 0, s);
+}
+*/
+import "C"
+
+import (
+	"time"
+	"unsafe"
+)
+
+// Slow query callbacks.
+var slowLogs handles[func(string, time.Duration)]
+
+// Calls fn with the SQL text and duration of each statement that runs for longer than the threshold
+// (nil to stop logging). The duration covers stepping the statement until it's reset or finalized.
+// It replaces any trace callback set with sqlite3_trace_v2.
+func (db *Database) SetSlowQueryLog(threshold time.Duration, fn func(sql string, d time.Duration)) {
+	db.clearSlowLog()
+	if fn == nil {
+		return
+	}
+	id := slowLogs.add(fn)
+	s := (*C.slow_log)(C.malloc(C.sizeof_slow_log))
+	s.handle = C.uintptr_t(id)
+	s.threshold = C.sqlite3_int64(threshold)
+	db.slowLog = unsafe.Pointer(s)
+	C.sqlite3_slow_log(db.db, s)
+}
+
+// Installs the slow query callback again on a reopened connection.
+func (db *Database) restoreSlowLog() {
+	if db.slowLog != nil {
+		C.sqlite3_slow_log(db.db, (*C.slow_log)(db.slowLog))
+	}
+}
+
+// Removes the slow query callback, if any.
+func (db *Database) clearSlowLog() {
+	if db.slowLog == nil {
+		return
+	}
+	C.sqlite3_slow_log(db.db, nil)
+	s := (*C.slow_log)(db.slowLog)
+	slowLogs.remove(uintptr(s.handle))
+	C.free(db.slowLog)
+	db.slowLog = nil
+}
+
+// Passes a statement that exceeded the threshold to the slow query callback with the handle.
+func slowQuery(id uintptr, sql string, d time.Duration) {
+	fn := slowLogs.get(id)
+	if fn != nil {
+		fn(sql, d)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetSlowQueryLog(t *testing.T) {
+	db := newTestDatabase(t)
+	const slow = "WITH RECURSIVE s(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM s WHERE n < 300000) SELECT count(*) FROM s"
+	var logged []string
+	var durations []time.Duration
+	db.SetSlowQueryLog(20*time.Millisecond, func(sql string, d time.Duration) {
+		logged = append(logged, sql)
+		durations = append(durations, d)
+	})
+	if err := db.Drain("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(logged) != 0 {
+		t.Fatalf("a fast query was logged: %v", logged)
+	}
+	if err := db.Drain(slow); err != nil {
+		t.Fatal(err)
+	}
+	if len(logged) != 1 || logged[0] != slow || durations[0] < 20*time.Millisecond {
+		t.Fatalf("got logged queries %v taking %v, want the slow one", logged, durations)
+	}
+	db.SetSlowQueryLog(0, nil)
+	if err := db.Drain(slow); err != nil {
+		t.Fatal(err)
+	}
+	if len(logged) != 1 {
+		t.Error("a query was logged after logging was stopped")
+	}
+}
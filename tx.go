@@ -0,0 +1,156 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import "errors"
+
+// A transaction.
+type Tx struct {
+	db     *Database
+	done   bool
+	dryRun bool // whether Commit is refused
+}
+
+// Begins a transaction using the provided BEGIN statement.
+func (db *Database) begin(sql string) (*Tx, error) {
+	if err := db.Execute(sql); err != nil {
+		return nil, err
+	}
+	return &Tx{db: db}, nil
+}
+
+// Begins a deferred transaction.
+func (db *Database) Begin() (*Tx, error) {
+	return db.begin("BEGIN")
+}
+
+// Begins a transaction that acquires the write lock immediately (BEGIN IMMEDIATE),
+// so that contention is reported here rather than on the first write.
+func (db *Database) BeginImmediate() (*Tx, error) {
+	return db.begin("BEGIN IMMEDIATE")
+}
+
+// Begins an exclusive transaction (BEGIN EXCLUSIVE).
+func (db *Database) BeginExclusive() (*Tx, error) {
+	return db.begin("BEGIN EXCLUSIVE")
+}
+
+// Executes an SQL statement in the transaction.
+func (tx *Tx) Execute(sql string) error {
+	return tx.db.Execute(sql)
+}
+
+// Returns a new statement in the transaction.
+func (tx *Tx) NewStatement(sql string) (*Statement, error) {
+	return tx.db.NewStatement(sql)
+}
+
+// Commits the transaction.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return errors.New("transaction already finished")
+	}
+	if tx.dryRun {
+		return errors.New("a dry run can't be committed")
+	}
+	// Discard a veto of an earlier commit outside the transaction.
+	tx.db.takeGuardError()
+	if err := tx.db.Execute("COMMIT"); err != nil {
+		if gerr := tx.db.takeGuardError(); gerr != nil {
+			// The vetoed transaction has been rolled back.
+			tx.done = true
+			return gerr
+		}
+		return err
+	}
+	tx.done = true
+	return nil
+}
+
+// Rolls the transaction back.
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return errors.New("transaction already finished")
+	}
+	tx.done = true
+	return tx.db.Execute("ROLLBACK")
+}
+
+// Runs fn in a transaction in which all statements see the same snapshot of the database
+// (under WAL, concurrent writes committed in the meantime aren't visible).
+func (db *Database) ReadTransaction(fn func(*Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	// A deferred transaction only takes its snapshot on the first read.
+	if err := db.Drain("SELECT 1 FROM sqlite_master LIMIT 1"); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Executes the statement for each row of arguments in one transaction, collecting failed rows instead of aborting.
+// Each row runs in its own savepoint so that a failing row doesn't affect the others.
+func (db *Database) ExecManyCollect(sql string, rows [][]interface{}) (succeeded int, failures []RowError, err error) {
+	stmt, err := db.NewStatement(sql)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer stmt.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	for i, args := range rows {
+		if err := db.Execute("SAVEPOINT exec_many_row"); err != nil {
+			tx.Rollback()
+			return 0, nil, err
+		}
+		if rerr := stmt.ExecArgs(args...); rerr != nil {
+			failures = append(failures, RowError{Index: i, Err: rerr})
+			if err := db.Execute("ROLLBACK TO exec_many_row"); err != nil {
+				tx.Rollback()
+				return 0, nil, err
+			}
+		} else {
+			succeeded++
+		}
+		if err := db.Execute("RELEASE exec_many_row"); err != nil {
+			tx.Rollback()
+			return 0, nil, err
+		}
+	}
+	stmt.Reset()
+	if err := tx.Commit(); err != nil {
+		tx.Rollback()
+		return 0, nil, err
+	}
+	return succeeded, failures, nil
+}
+
+// Runs fn in a transaction that is always rolled back and returns the number of rows
+// it would have changed. Committing the transaction passed to fn fails.
+func (db *Database) DryRun(fn func(*Tx) error) (changes int64, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	tx.dryRun = true
+	before := db.TotalChanges()
+	err = fn(tx)
+	changes = db.TotalChanges() - before
+	if !tx.done {
+		if err2 := tx.Rollback(); err == nil {
+			err = err2
+		}
+	}
+	return changes, err
+}
@@ -0,0 +1,130 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns two connections to a new WAL database file with the table items.
+func walDatabases(t *testing.T) (a, b *Database) {
+	t.Helper()
+	path := t.TempDir() + "/wal.db"
+	opts := &Options{JournalMode: JournalWAL}
+	a, err := Open(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(a.Close)
+	b, err = Open(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(b.Close)
+	mustExecute(t, a, "CREATE TABLE items (name TEXT); INSERT INTO items VALUES ('alpha')")
+	return a, b
+}
+
+func TestReadTransaction(t *testing.T) {
+	reader, writer := walDatabases(t)
+	var counts []int64
+	err := reader.ReadTransaction(func(tx *Tx) error {
+		n, err := reader.queryInt64("SELECT count(*) FROM items")
+		if err != nil {
+			return err
+		}
+		counts = append(counts, n)
+		if err := writer.Execute("INSERT INTO items VALUES ('beta')"); err != nil {
+			return err
+		}
+		n, err = reader.queryInt64("SELECT count(*) FROM items")
+		counts = append(counts, n)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if counts[0] != 1 || counts[1] != 1 {
+		t.Errorf("got counts %v inside the transaction, want [1 1]", counts)
+	}
+	if n, _ := reader.queryInt64("SELECT count(*) FROM items"); n != 2 {
+		t.Errorf("got %d rows after the transaction, want 2", n)
+	}
+}
+
+func TestExecManyCollect(t *testing.T) {
+	db := newTestDatabase(t)
+	mustExecute(t, db, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT NOT NULL)")
+	rows := [][]interface{}{{1, "alpha"}, {2, nil}, {3, "gamma"}, {1, "duplicate"}, {5, "epsilon"}}
+	succeeded, failures, err := db.ExecManyCollect("INSERT INTO items VALUES (?, ?)", rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if succeeded != 3 {
+		t.Errorf("got %d succeeded rows, want 3", succeeded)
+	}
+	if len(failures) != 2 || failures[0].Index != 1 || failures[1].Index != 3 {
+		t.Errorf("got failures %v, want rows 1 and 3", failures)
+	}
+	if n, _ := db.queryInt64("SELECT count(*) FROM items"); n != 3 {
+		t.Errorf("got %d committed rows, want 3", n)
+	}
+}
+
+func TestBeginImmediate(t *testing.T) {
+	a, b := walDatabases(t)
+	txa, err := a.BeginImmediate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.BeginImmediate(); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("got %v, want a database is locked error", err)
+	}
+	// With a busy timeout the second writer waits for the first to commit.
+	b.SetBusyTimeout(5 * time.Second)
+	done := make(chan error, 1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		done <- txa.Commit()
+	}()
+	txb, err := b.BeginImmediate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := txb.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	db := newTestDatabase(t)
+	createNums(t, db, 10)
+	changes, err := db.DryRun(func(tx *Tx) error {
+		if err := tx.Execute("DELETE FROM nums WHERE n > 7; UPDATE nums SET n = n * 100 WHERE n < 3"); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err == nil {
+			t.Error("committing a dry run succeeded")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes != 5 {
+		t.Errorf("got %d changes, want 5", changes)
+	}
+	if n, _ := db.queryInt64("SELECT sum(n) FROM nums"); n != 55 {
+		t.Errorf("got a sum of %d after the dry run, want the unchanged 55", n)
+	}
+	if db.InTransaction() {
+		t.Error("the dry run left a transaction open")
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <stdlib.h>
+#include <stdint.h>
+#include <string.h>
+#include <sqlite3.h>
+extern int goVFSOpen(uintptr_t, char*, int);
+extern int goVFSRead(uintptr_t, char*, sqlite3_int64, int);
+extern int goVFSWrite(uintptr_t, char*, void*, int, sqlite3_int64);
+extern int goVFSSync(uintptr_t, char*);
+extern int goVFSClose(uintptr_t, char*);
+
+// A VFS that forwards to another one, reporting file operations to Go.
+typedef struct go_vfs {
+	sqlite3_vfs base;
+	sqlite3_vfs* root;
+	uintptr_t handle;
+} go_vfs;
+
+typedef struct go_vfs_file {
+	sqlite3_file base;
+	go_vfs* vfs;
+	char* name;
+	sqlite3_file* real;
+} go_vfs_file;
+
+#define GO_VFS_ROOT(v) (((go_vfs*)(v))->root)
+#define GO_VFS_REAL(f) (((go_vfs_file*)(f))->real)
+
+static int go_vfs_file_close(sqlite3_file* f) {
+	go_vfs_file* p = (go_vfs_file*)f;
+	int rc = goVFSClose(p->vfs->handle, p->name);
+	int rc2 = p->real->pMethods->xClose(p->real);
+	return rc != SQLITE_OK ? rc : rc2;
+}
+static int go_vfs_file_read(sqlite3_file* f, void* buf, int n, sqlite3_int64 off) {
+	go_vfs_file* p = (go_vfs_file*)f;
+	int rc = goVFSRead(p->vfs->handle, p->name, off, n);
+	if (rc != SQLITE_OK) return rc;
+	return p->real->pMethods->xRead(p->real, buf, n, off);
+}
+static int go_vfs_file_write(sqlite3_file* f, const void* buf, int n, sqlite3_int64 off) {
+	go_vfs_file* p = (go_vfs_file*)f;
+	int rc = goVFSWrite(p->vfs->handle, p->name, (void*)buf, n, off);
+	if (rc != SQLITE_OK) return rc;
+	return p->real->pMethods->xWrite(p->real, buf, n, off);
+}
+static int go_vfs_file_sync(sqlite3_file* f, int flags) {
+	go_vfs_file* p = (go_vfs_file*)f;
+	int rc = goVFSSync(p->vfs->handle, p->name);
+	if (rc != SQLITE_OK) return rc;
+	return p->real->pMethods->xSync(p->real, flags);
+}
+static int go_vfs_file_truncate(sqlite3_file* f, sqlite3_int64 size) { return GO_VFS_REAL(f)->pMethods->xTruncate(GO_VFS_REAL(f), size); }
+static int go_vfs_file_size(sqlite3_file* f, sqlite3_int64* size) { return GO_VFS_REAL(f)->pMethods->xFileSize(GO_VFS_REAL(f), size); }
+static int go_vfs_file_lock(sqlite3_file* f, int lock) { return GO_VFS_REAL(f)->pMethods->xLock(GO_VFS_REAL(f), lock); }
+static int go_vfs_file_unlock(sqlite3_file* f, int lock) { return GO_VFS_REAL(f)->pMethods->xUnlock(GO_VFS_REAL(f), lock); }
+static int go_vfs_file_reserved(sqlite3_file* f, int* out) { return GO_VFS_REAL(f)->pMethods->xCheckReservedLock(GO_VFS_REAL(f), out); }
+static int go_vfs_file_control(sqlite3_file* f, int op, void* arg) { return GO_VFS_REAL(f)->pMethods->xFileControl(GO_VFS_REAL(f), op, arg); }
+static int go_vfs_file_sector(sqlite3_file* f) { return GO_VFS_REAL(f)->pMethods->xSectorSize(GO_VFS_REAL(f)); }
+static int go_vfs_file_device(sqlite3_file* f) { return GO_VFS_REAL(f)->pMethods->xDeviceCharacteristics(GO_VFS_REAL(f)); }
+static int go_vfs_file_shm_map(sqlite3_file* f, int pg, int pgsz, int extend, void volatile** pp) { return GO_VFS_REAL(f)->pMethods->xShmMap(GO_VFS_REAL(f), pg, pgsz, extend, pp); }
+static int go_vfs_file_shm_lock(sqlite3_file* f, int off, int n, int flags) { return GO_VFS_REAL(f)->pMethods->xShmLock(GO_VFS_REAL(f), off, n, flags); }
+static void go_vfs_file_shm_barrier(sqlite3_file* f) { GO_VFS_REAL(f)->pMethods->xShmBarrier(GO_VFS_REAL(f)); }
+static int go_vfs_file_shm_unmap(sqlite3_file* f, int del) { return GO_VFS_REAL(f)->pMethods->xShmUnmap(GO_VFS_REAL(f), del); }
+
+// Version 2 leaves out memory-mapped I/O, which would bypass xRead and xWrite.
+static const sqlite3_io_methods go_vfs_io_methods = {
+	2, go_vfs_file_close, go_vfs_file_read, go_vfs_file_write, go_vfs_file_truncate, go_vfs_file_sync,
+	go_vfs_file_size, go_vfs_file_lock, go_vfs_file_unlock, go_vfs_file_reserved, go_vfs_file_control,
+	go_vfs_file_sector, go_vfs_file_device, go_vfs_file_shm_map, go_vfs_file_shm_lock,
+	go_vfs_file_shm_barrier, go_vfs_file_shm_unmap,
+};
+
+static int go_vfs_open(sqlite3_vfs* v, const char* name, sqlite3_file* f, int flags, int* outFlags) {
+	go_vfs_file* p = (go_vfs_file*)f;
+	p->base.pMethods = 0;
+	p->vfs = (go_vfs*)v;
+	p->name = (char*)(name ? name : "");
+	p->real = (sqlite3_file*)&p[1];
+	int rc = goVFSOpen(p->vfs->handle, p->name, flags);
+	if (rc != SQLITE_OK) return rc;
+	rc = GO_VFS_ROOT(v)->xOpen(GO_VFS_ROOT(v), name, p->real, flags, outFlags);
+	if (p->real->pMethods) p->base.pMethods = &go_vfs_io_methods;
+	return rc;
+}
+static int go_vfs_delete(sqlite3_vfs* v, const char* name, int sync) { return GO_VFS_ROOT(v)->xDelete(GO_VFS_ROOT(v), name, sync); }
+static int go_vfs_access(sqlite3_vfs* v, const char* name, int flags, int* out) { return GO_VFS_ROOT(v)->xAccess(GO_VFS_ROOT(v), name, flags, out); }
+static int go_vfs_full_pathname(sqlite3_vfs* v, const char* name, int n, char* out) { return GO_VFS_ROOT(v)->xFullPathname(GO_VFS_ROOT(v), name, n, out); }
+static void* go_vfs_dl_open(sqlite3_vfs* v, const char* name) { return GO_VFS_ROOT(v)->xDlOpen(GO_VFS_ROOT(v), name); }
+static void go_vfs_dl_error(sqlite3_vfs* v, int n, char* msg) { GO_VFS_ROOT(v)->xDlError(GO_VFS_ROOT(v), n, msg); }
+static void (*go_vfs_dl_sym(sqlite3_vfs* v, void* h, const char* sym))(void) { return GO_VFS_ROOT(v)->xDlSym(GO_VFS_ROOT(v), h, sym); }
+static void go_vfs_dl_close(sqlite3_vfs* v, void* h) { GO_VFS_ROOT(v)->xDlClose(GO_VFS_ROOT(v), h); }
+static int go_vfs_randomness(sqlite3_vfs* v, int n, char* out) { return GO_VFS_ROOT(v)->xRandomness(GO_VFS_ROOT(v), n, out); }
+static int go_vfs_sleep(sqlite3_vfs* v, int us) { return GO_VFS_ROOT(v)->xSleep(GO_VFS_ROOT(v), us); }
+static int go_vfs_current_time(sqlite3_vfs* v, double* out) { return GO_VFS_ROOT(v)->xCurrentTime(GO_VFS_ROOT(v), out); }
+static int go_vfs_last_error(sqlite3_vfs* v, int n, char* out) { return GO_VFS_ROOT(v)->xGetLastError(GO_VFS_ROOT(v), n, out); }
+static int go_vfs_current_time_int64(sqlite3_vfs* v, sqlite3_int64* out) { return GO_VFS_ROOT(v)->xCurrentTimeInt64(GO_VFS_ROOT(v), out); }
+
+static inline int sqlite3_register_go_vfs(const char* name, uintptr_t handle) {
+	sqlite3_vfs* root = sqlite3_vfs_find(0);
+	if (root == 0 || root->iVersion < 2) return SQLITE_ERROR;
+	go_vfs* v = sqlite3_malloc(sizeof(go_vfs));
+	if (v == 0) return SQLITE_NOMEM;
+	memset(v, 0, sizeof(go_vfs));
+	v->base.iVersion = 2;
+	v->base.szOsFile = sizeof(go_vfs_file) + root->szOsFile;
+	v->base.mxPathname = root->mxPathname;
+	v->base.zName = sqlite3_mprintf("%s", name);
+	v->base.xOpen = go_vfs_open;
+	v->base.xDelete = go_vfs_delete;
+	v->base.xAccess = go_vfs_access;
+	v->base.xFullPathname = go_vfs_full_pathname;
+	v->base.xDlOpen = go_vfs_dl_open;
+	v->base.xDlError = go_vfs_dl_error;
+	v->base.xDlSym = go_vfs_dl_sym;
+	v->base.xDlClose = go_vfs_dl_close;
+	v->base.xRandomness = go_vfs_randomness;
+	v->base.xSleep = go_vfs_sleep;
+	v->base.xCurrentTime = go_vfs_current_time;
+	v->base.xGetLastError = go_vfs_last_error;
+	v->base.xCurrentTimeInt64 = go_vfs_current_time_int64;
+	v->root = root;
+	v->handle = handle;
+	return sqlite3_vfs_register(&v->base, 0);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// Hooks called by a VFS registered with RegisterVFS before each file operation is passed on
+// to the default VFS. Returning an error fails the operation with the corresponding
+// SQLITE_CANTOPEN or SQLITE_IOERR_* code instead, e.g. to inject faults or slow down I/O in tests.
+type VFS interface {
+	Open(name string, flags int) error
+	Read(name string, offset int64, n int) error
+	Write(name string, offset int64, data []byte) error
+	Sync(name string) error
+	Close(name string) error
+}
+
+// A VFS that passes every operation through unchanged. It can be embedded to override
+// only some of the hooks.
+type PassThroughVFS struct{}
+
+func (PassThroughVFS) Open(name string, flags int) error                  { return nil }
+func (PassThroughVFS) Read(name string, offset int64, n int) error        { return nil }
+func (PassThroughVFS) Write(name string, offset int64, data []byte) error { return nil }
+func (PassThroughVFS) Sync(name string) error                             { return nil }
+func (PassThroughVFS) Close(name string) error                            { return nil }
+
+// Registered VFS implementations.
+var vfsHandles handles[VFS]
+
+// Registers a VFS with the name that wraps the default VFS, calling the hooks of vfs
+// for each file operation. It's used by databases opened with Options.VFS set to the name.
+// Registered VFSes can't be unregistered.
+func RegisterVFS(name string, vfs VFS) error {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	if C.sqlite3_vfs_find(cname) != nil {
+		return fmt.Errorf("VFS %s is already registered", name)
+	}
+	id := vfsHandles.add(vfs)
+	if s := C.sqlite3_register_go_vfs(cname, C.uintptr_t(id)); s != C.SQLITE_OK {
+		vfsHandles.remove(id)
+		return errors.New(C.GoString(C.sqlite3_errstr(s)))
+	}
+	return nil
+}
+
+// Converts an error returned by a hook to the result code.
+func vfsResult(err error, code C.int) C.int {
+	if err != nil {
+		return code
+	}
+	return C.SQLITE_OK
+}
@@ -0,0 +1,76 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// A VFS counting writes to the main database file that can be made to fail them.
+type faultyVFS struct {
+	PassThroughVFS
+	sync.Mutex
+	opened []string
+	writes int
+	fail   bool
+}
+
+func (v *faultyVFS) Open(name string, flags int) error {
+	v.Lock()
+	defer v.Unlock()
+	v.opened = append(v.opened, name)
+	return nil
+}
+
+func (v *faultyVFS) Write(name string, offset int64, data []byte) error {
+	v.Lock()
+	defer v.Unlock()
+	if v.fail {
+		return errors.New("injected fault")
+	}
+	v.writes++
+	return nil
+}
+
+func TestRegisterVFS(t *testing.T) {
+	vfs := &faultyVFS{}
+	if err := RegisterVFS("faulty_test", vfs); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterVFS("faulty_test", vfs); err == nil {
+		t.Error("registering a VFS twice succeeded")
+	}
+	path := t.TempDir() + "/vfs.db"
+	opts := DefaultOptions()
+	opts.VFS = "faulty_test"
+	db, err := Open(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mustExecute(t, db, "CREATE TABLE items (name TEXT); INSERT INTO items VALUES ('alpha')")
+	vfs.Lock()
+	opened, writes := vfs.opened, vfs.writes
+	vfs.fail = true
+	vfs.Unlock()
+	if len(opened) == 0 || !strings.HasSuffix(opened[0], "/vfs.db") {
+		t.Errorf("got opened files %v, want the database first", opened)
+	}
+	if writes == 0 {
+		t.Error("no writes went through the VFS")
+	}
+	if err := db.Execute("INSERT INTO items VALUES ('beta')"); err == nil {
+		t.Error("a write succeeded although the VFS failed it")
+	}
+	vfs.Lock()
+	vfs.fail = false
+	vfs.Unlock()
+	if n, err := db.queryInt64("SELECT count(*) FROM items"); err != nil || n != 1 {
+		t.Errorf("got %d rows (%v) after the failed write, want 1", n, err)
+	}
+}
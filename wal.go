@@ -0,0 +1,98 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+/*
+#include <sqlite3.h>
+*/
+import "C"
+
+import "errors"
+
+// Runs PRAGMA wal_checkpoint with the mode on the schema and returns its busy flag,
+// the number of frames in the WAL and the number of checkpointed frames (-1 if not in WAL mode).
+func (db *Database) walCheckpoint(schema, mode string) (busy bool, log, checkpointed int, err error) {
+	stmt, err := db.NewStatement("PRAGMA " + QuoteIdentifier(schema) + ".wal_checkpoint(" + mode + ")")
+	if err != nil {
+		return false, 0, 0, err
+	}
+	defer stmt.Close()
+	if C.sqlite3_step(stmt.stmt) != C.SQLITE_ROW {
+		return false, 0, 0, errors.New(C.GoString(C.sqlite3_errmsg(db.db)))
+	}
+	return stmt.ColumnInt(0) != 0, stmt.ColumnInt(1), stmt.ColumnInt(2), nil
+}
+
+// Returns the number of frames in the WAL file of the schema ("main" if empty), or -1 if it isn't in WAL mode.
+// It runs a PASSIVE checkpoint, which doesn't wait for readers or writers.
+func (db *Database) WALFrameCount(schema string) (int, error) {
+	if schema == "" {
+		schema = "main"
+	}
+	_, log, _, err := db.walCheckpoint(schema, "PASSIVE")
+	return log, err
+}
+
+// Returns true if a PASSIVE checkpoint of the main database can't copy the whole WAL back,
+// which usually means a reader is holding an old snapshot. It's false if not in WAL mode.
+func (db *Database) CheckpointBlocked() (bool, error) {
+	_, log, checkpointed, err := db.walCheckpoint("main", "PASSIVE")
+	if err != nil {
+		return false, err
+	}
+	return log >= 0 && checkpointed < log, nil
+}
+
+// A checkpoint mode.
+type CheckpointMode string
+
+const (
+	CheckpointPassive  CheckpointMode = "PASSIVE"
+	CheckpointFull     CheckpointMode = "FULL"
+	CheckpointRestart  CheckpointMode = "RESTART"
+	CheckpointTruncate CheckpointMode = "TRUNCATE"
+)
+
+// The result of PRAGMA wal_checkpoint.
+type CheckpointResult struct {
+	// Whether the checkpoint couldn't complete because of other connections.
+	Busy bool
+	// The number of frames in the WAL file (-1 if not in WAL mode).
+	LogFrames int
+	// The number of frames checkpointed (-1 if not in WAL mode).
+	CheckpointedFrames int
+}
+
+// Checkpoints the main database with the mode using PRAGMA wal_checkpoint.
+func (db *Database) CheckpointResult(mode CheckpointMode) (CheckpointResult, error) {
+	busy, log, checkpointed, err := db.walCheckpoint("main", string(mode))
+	return CheckpointResult{Busy: busy, LogFrames: log, CheckpointedFrames: checkpointed}, err
+}
+
+// Checkpoints every schema listed by PRAGMA database_list (main, temp and attached databases)
+// with the mode and returns the results by schema name.
+func (db *Database) CheckpointAll(mode CheckpointMode) (map[string]CheckpointResult, error) {
+	stmt, err := db.NewStatement("PRAGMA database_list")
+	if err != nil {
+		return nil, err
+	}
+	var schemas []string
+	err = stmt.StepRows(func() {
+		schemas = append(schemas, stmt.ColumnText(1))
+	})
+	stmt.Close()
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]CheckpointResult, len(schemas))
+	for _, schema := range schemas {
+		busy, log, checkpointed, err := db.walCheckpoint(schema, string(mode))
+		if err != nil {
+			return nil, err
+		}
+		results[schema] = CheckpointResult{Busy: busy, LogFrames: log, CheckpointedFrames: checkpointed}
+	}
+	return results, nil
+}
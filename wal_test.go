@@ -0,0 +1,94 @@
+// Copyright 2018-2020 Petr Homola. All rights reserved.
+// Use of this source code is governed by the AGPL v3.0
+// that can be found in the LICENSE file.
+
+package sqlite
+
+import "testing"
+
+func TestWALFrameCount(t *testing.T) {
+	db, _ := walDatabases(t)
+	mustExecute(t, db, "INSERT INTO items VALUES ('beta'), ('gamma')")
+	n, err := db.WALFrameCount("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n <= 0 {
+		t.Errorf("got %d frames after writes, want a positive count", n)
+	}
+	if _, err := db.CheckpointResult(CheckpointTruncate); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := db.WALFrameCount("main"); err != nil || n != 0 {
+		t.Errorf("got %d frames (%v) after a TRUNCATE checkpoint, want 0", n, err)
+	}
+	memory := newTestDatabase(t)
+	if n, err := memory.WALFrameCount(""); err != nil || n != -1 {
+		t.Errorf("got %d frames (%v) outside WAL mode, want -1", n, err)
+	}
+}
+
+func TestCheckpointBlocked(t *testing.T) {
+	writer, reader := walDatabases(t)
+	tx, err := reader.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.queryInt64("SELECT count(*) FROM items"); err != nil {
+		t.Fatal(err)
+	}
+	mustExecute(t, writer, "INSERT INTO items VALUES ('beta')")
+	if blocked, err := writer.CheckpointBlocked(); err != nil || !blocked {
+		t.Errorf("got blocked %v (%v) with an open read transaction, want true", blocked, err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if blocked, err := writer.CheckpointBlocked(); err != nil || blocked {
+		t.Errorf("got blocked %v (%v) after the read transaction ended, want false", blocked, err)
+	}
+}
+
+func TestCheckpointResult(t *testing.T) {
+	db, _ := walDatabases(t)
+	mustExecute(t, db, "INSERT INTO items VALUES ('beta'), ('gamma')")
+	res, err := db.CheckpointResult(CheckpointTruncate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A truncated WAL file reports no frames.
+	if res != (CheckpointResult{}) {
+		t.Errorf("got %+v, want an uncontended checkpoint leaving no frames", res)
+	}
+	mustExecute(t, db, "INSERT INTO items VALUES ('delta')")
+	res, err = db.CheckpointResult(CheckpointPassive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Busy || res.LogFrames <= 0 || res.CheckpointedFrames != res.LogFrames {
+		t.Errorf("got %+v, want all frames checkpointed", res)
+	}
+}
+
+func TestCheckpointAll(t *testing.T) {
+	db, _ := walDatabases(t)
+	if err := db.Attach(t.TempDir()+"/attached.db", "att"); err != nil {
+		t.Fatal(err)
+	}
+	mustExecute(t, db, "PRAGMA att.journal_mode = WAL; CREATE TABLE att.more (x); INSERT INTO att.more VALUES (1)")
+	mustExecute(t, db, "INSERT INTO items VALUES ('beta')")
+	for _, schema := range []string{"main", "att"} {
+		if n, err := db.WALFrameCount(schema); err != nil || n <= 0 {
+			t.Fatalf("%s: got %d frames (%v) before the checkpoint, want a positive count", schema, n, err)
+		}
+	}
+	results, err := db.CheckpointAll(CheckpointTruncate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, schema := range []string{"main", "att"} {
+		if res, ok := results[schema]; !ok || res != (CheckpointResult{}) {
+			t.Errorf("%s: got %+v (%v), want a complete checkpoint leaving no frames", schema, res, ok)
+		}
+	}
+}